@@ -0,0 +1,268 @@
+// Command positive-news runs the pipeline stages locally, one at a time or end to end.
+//
+// Usage:
+//
+//	positive-news generate [-dry-run] [-out dir] [-force]
+//	positive-news fetch [-o candidates.json]
+//	positive-news rank [-i candidates.json] [-o ranked.json]
+//	positive-news render [-date YYYY-MM-DD] [-html]
+//	positive-news subscribers list [-status active]
+//	positive-news subscribers add [-name name] [-categories a,b] <email>
+//	positive-news subscribers remove <email>
+//	positive-news subscribers import
+//
+// Configuration is read the same way as the Lambda function (see helpers.LoadConfig).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"positive-news/helpers"
+	"strings"
+	"time"
+)
+
+const usage = `usage: positive-news <command> [flags]
+
+commands:
+  generate [-dry-run] [-out dir] [-force]
+                                 run the full pipeline and send the digest
+  fetch  [-o file]               fetch and filter candidate articles
+  rank   [-i file] [-o file]     rank a JSON file of candidates
+  render [-date YYYY-MM-DD] [-html]
+                                 render the email for a day's stored digest
+  subscribers list [-status s]   list subscribers with a status (default active)
+  subscribers add [-name n] [-categories a,b] <email>
+                                 subscribe an email address
+  subscribers remove <email>     unsubscribe an email address
+  subscribers import             copy confirmed SNS topic subscriptions into the store
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cfg, err := helpers.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load configuration:", err)
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	cmd, args := os.Args[1], os.Args[2:]
+	switch cmd {
+	case "generate":
+		err = runGenerate(ctx, cfg, args)
+	case "fetch":
+		err = runFetch(ctx, cfg, args)
+	case "rank":
+		err = runRank(ctx, cfg, args)
+	case "render":
+		err = runRender(ctx, cfg, args)
+	case "subscribers":
+		err = runSubscribers(ctx, cfg, args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// runGenerate runs the same workflow as the scheduled Lambda invocation.
+func runGenerate(ctx context.Context, cfg helpers.Config, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", cfg.DryRun, "write the email, ranking and index.html instead of publishing and sending")
+	out := fs.String("out", cfg.DryRunDir, "local directory for dry run output (default: the artifact bucket or a temp directory)")
+	force := fs.Bool("force", cfg.ForceRun, "rerun even if today's digest was already sent")
+	fs.Parse(args)
+	cfg.ForceRun = *force
+	cfg.DryRun = *dryRun
+	fs.Visit(func(f *flag.Flag) {
+		// Passing -out always means a dry run, even when it names the configured directory.
+		if f.Name == "out" {
+			cfg.DryRun = true
+		}
+	})
+	cfg.DryRunDir = *out
+	return helpers.GenerateDigest(ctx, cfg)
+}
+
+// runFetch writes the filtered candidates as JSON, ready for the rank command.
+func runFetch(ctx context.Context, cfg helpers.Config, args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	out := fs.String("o", "candidates.json", "output file")
+	fs.Parse(args)
+
+	newsAPIKey, _, err := helpers.GetSecrets(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error retrieving secrets: %w", err)
+	}
+	store, err := helpers.NewArticleStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error opening article store: %w", err)
+	}
+	articles, err := helpers.FetchStage(ctx, cfg, store, helpers.NewsSources(cfg, newsAPIKey))
+	if err != nil {
+		return err
+	}
+	return writeJSON(*out, articles)
+}
+
+// runRank ranks a candidates file written by fetch.
+func runRank(ctx context.Context, cfg helpers.Config, args []string) error {
+	fs := flag.NewFlagSet("rank", flag.ExitOnError)
+	in := fs.String("i", "candidates.json", "candidates file")
+	out := fs.String("o", "ranked.json", "output file")
+	fs.Parse(args)
+
+	var articles []helpers.ArticleWithContent
+	if err := readJSON(*in, &articles); err != nil {
+		return err
+	}
+	openaiAPIKey := os.Getenv("OPENAI_API_KEY")
+	if openaiAPIKey == "" && cfg.RankerBaseURL == "" {
+		_, key, err := helpers.GetSecrets(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("error retrieving secrets: %w", err)
+		}
+		openaiAPIKey = key
+	}
+	ranked, _, err := helpers.RankStage(ctx, cfg, openaiAPIKey, articles)
+	if err != nil {
+		return err
+	}
+	return writeJSON(*out, ranked)
+}
+
+// runRender prints the email for the last digest sent on a day, rebuilt from stored articles.
+func runRender(ctx context.Context, cfg helpers.Config, args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	date := fs.String("date", time.Now().UTC().Format("2006-01-02"), "day of the digest (UTC)")
+	html := fs.Bool("html", false, "print the HTML part instead of the plain text")
+	fs.Parse(args)
+
+	day, err := time.Parse("2006-01-02", *date)
+	if err != nil {
+		return fmt.Errorf("invalid -date: %w", err)
+	}
+	store, err := helpers.NewArticleStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error opening article store: %w", err)
+	}
+	articles, err := helpers.DigestArticlesOn(ctx, store, day)
+	if err != nil {
+		return err
+	}
+	if len(articles) == 0 {
+		return fmt.Errorf("no stored articles for %s", *date)
+	}
+	email, err := helpers.RenderDigest(cfg, articles)
+	if err != nil {
+		return err
+	}
+	if *html {
+		fmt.Print(email.HTML)
+		return nil
+	}
+	fmt.Println("Subject:", email.Subject)
+	fmt.Println()
+	fmt.Print(email.Text)
+	return nil
+}
+
+// runSubscribers manages the subscriber store.
+func runSubscribers(ctx context.Context, cfg helpers.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("subscribers needs one of: list, add, remove, import")
+	}
+	store, err := helpers.NewSubscriberStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("subscribers list", flag.ExitOnError)
+		status := fs.String("status", helpers.SubscriberActive, "pending, active, unsubscribed or bounced")
+		fs.Parse(args[1:])
+		subs, err := store.SubscribersByStatus(ctx, *status)
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			fmt.Printf("%s\t%s\t%s\t%s\n", sub.Email, sub.Name, sub.CreatedAt.Format("2006-01-02"),
+				strings.Join(sub.Preferences.Categories, ","))
+		}
+		fmt.Fprintf(os.Stderr, "%d %s subscribers\n", len(subs), *status)
+		return nil
+	case "add":
+		fs := flag.NewFlagSet("subscribers add", flag.ExitOnError)
+		name := fs.String("name", "", "subscriber's name")
+		categories := fs.String("categories", "", "comma-separated categories to limit the digest to")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: subscribers add [-name name] [-categories a,b] <email>")
+		}
+		var prefs helpers.SubscriberPreferences
+		if *categories != "" {
+			prefs.Categories = strings.Split(*categories, ",")
+		}
+		msg, err := helpers.Subscribe(ctx, cfg, store, fs.Arg(0), *name, prefs)
+		if err != nil {
+			return err
+		}
+		fmt.Println(msg)
+		return nil
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: subscribers remove <email>")
+		}
+		msg, err := helpers.Unsubscribe(ctx, cfg, store, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(msg)
+		return nil
+	case "import":
+		added, err := helpers.ImportTopicSubscribers(ctx, cfg, store)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d subscribers from the SNS topic\n", added)
+		return nil
+	default:
+		return fmt.Errorf("unknown subscribers command %q", args[0])
+	}
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Wrote", path)
+	return nil
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
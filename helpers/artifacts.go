@@ -0,0 +1,117 @@
+// artifacts.go
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ArtifactStore keeps files produced by a run somewhere other than their live location.
+type ArtifactStore interface {
+	WriteArtifact(ctx context.Context, name string, data []byte, contentType string) error
+	// ReadArtifact returns an error wrapping os.ErrNotExist if the artifact was never written.
+	ReadArtifact(ctx context.Context, name string) ([]byte, error)
+	// Location describes where an artifact with the given name ends up, for logging.
+	Location(name string) string
+}
+
+// NewArtifactStore returns the store for a run's artifacts: a run ID subdirectory of dir when it
+// is set, otherwise a run ID folder under prefix in cfg.ArtifactBucket. Without either they go to
+// a temporary directory named after prefix. Artifacts never go to the public website bucket.
+func NewArtifactStore(ctx context.Context, cfg Config, dir, prefix, runID string) (ArtifactStore, error) {
+	if dir == "" && cfg.ArtifactBucket == "" {
+		dir = filepath.Join(os.TempDir(), "positive-news", strings.Trim(prefix, "/"))
+	}
+	if dir != "" {
+		return &DirArtifacts{Dir: filepath.Join(dir, runID)}, nil
+	}
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Artifacts{
+		Client: s3.NewFromConfig(awsCfg),
+		Bucket: cfg.ArtifactBucket,
+		Prefix: path.Join(prefix, runID) + "/",
+	}, nil
+}
+
+// DirArtifacts writes artifacts as files in a local directory.
+type DirArtifacts struct {
+	Dir string
+}
+
+// WriteArtifact writes data to Dir/name, creating Dir if needed.
+func (d *DirArtifacts) WriteArtifact(ctx context.Context, name string, data []byte, contentType string) error {
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", d.Dir, err)
+	}
+	if err := ioutil.WriteFile(d.Location(name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadArtifact reads Dir/name.
+func (d *DirArtifacts) ReadArtifact(ctx context.Context, name string) ([]byte, error) {
+	return ioutil.ReadFile(d.Location(name))
+}
+
+// Location returns the file path of the artifact.
+func (d *DirArtifacts) Location(name string) string {
+	return filepath.Join(d.Dir, name)
+}
+
+// S3Artifacts writes artifacts as objects under a key prefix.
+type S3Artifacts struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+}
+
+// WriteArtifact uploads data to Prefix+name.
+func (a *S3Artifacts) WriteArtifact(ctx context.Context, name string, data []byte, contentType string) error {
+	_, err := a.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.Bucket),
+		Key:         aws.String(a.Prefix + name),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to S3: %w", name, err)
+	}
+	return nil
+}
+
+// ReadArtifact downloads Prefix+name.
+func (a *S3Artifacts) ReadArtifact(ctx context.Context, name string) ([]byte, error) {
+	resp, err := a.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.Bucket),
+		Key:    aws.String(a.Prefix + name),
+	})
+	if err != nil {
+		var noSuchKey *s3Types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%s: %w", a.Location(name), os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to download %s from S3: %w", name, err)
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Location returns the s3:// URI of the artifact.
+func (a *S3Artifacts) Location(name string) string {
+	return fmt.Sprintf("s3://%s/%s%s", a.Bucket, a.Prefix, name)
+}
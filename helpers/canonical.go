@@ -0,0 +1,170 @@
+// canonical.go
+package helpers
+
+import (
+	"bytes"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// trackingParams are query parameters that never change which article a URL points to.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true, "_hsenc": true, "_hsmi": true, "mkt_tok": true,
+	"ocid": true, "cmpid": true, "cmp": true, "icid": true, "ncid": true, "s_cid": true, "spm": true,
+	"ref": true, "ref_src": true, "referrer": true, "smid": true, "smtyp": true, "sr_share": true, "taid": true,
+	"guccounter": true, "guce_referrer": true, "guce_referrer_sig": true, "share": true, "amp": true,
+	"ns_source": true, "ns_mchannel": true, "ns_campaign": true, "at_medium": true, "at_campaign": true,
+}
+
+// mobileHostPrefixes are subdomains that serve a mobile or AMP copy of the main site. "www." is
+// listed too since sites serve the same pages with and without it.
+var mobileHostPrefixes = []string{"www.", "m.", "mobile.", "amp."}
+
+// CanonicalURL normalizes an article URL for use as a dedup and storage key.
+// It lower-cases the scheme and host, uses https for http, drops default ports, www and
+// mobile/AMP subdomains, fragments, tracking parameters and AMP path variants, sorts the remaining
+// query, and trims trailing slashes. The result is a key, not necessarily a working link.
+// Unparseable input is returned trimmed but otherwise unchanged.
+func CanonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	for _, prefix := range mobileHostPrefixes {
+		if strings.HasPrefix(host, prefix) && strings.Count(host, ".") >= 2 {
+			host = strings.TrimPrefix(host, prefix)
+			break
+		}
+	}
+	if port == "" || port == "80" || port == "443" {
+		u.Host = host
+	} else {
+		u.Host = host + ":" + port
+	}
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	path := u.EscapedPath()
+	path = strings.TrimPrefix(path, "/amp/")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	for _, suffix := range []string{"/amp/", "/amp", ".amp.html", ".amp"} {
+		if strings.HasSuffix(path, suffix) && len(path) > len(suffix) {
+			path = strings.TrimSuffix(path, suffix)
+			if suffix == ".amp.html" {
+				path += ".html"
+			}
+			break
+		}
+	}
+	path = strings.TrimRight(path, "/")
+	if p, err := url.PathUnescape(path); err == nil {
+		u.Path = p
+		u.RawPath = path
+	}
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+	}
+	if v := query.Get("outputType"); strings.EqualFold(v, "amp") {
+		query.Del("outputType")
+	}
+	u.RawQuery = encodeSortedQuery(query)
+	return u.String()
+}
+
+// encodeSortedQuery encodes query parameters with keys and values in a stable order.
+func encodeSortedQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// findCanonicalLink returns the rel=canonical link declared in an HTML document, resolved against base.
+// It returns "" if the page declares none.
+func findCanonicalLink(page []byte, base *url.URL) string {
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return ""
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) == "body" {
+				return ""
+			}
+			if string(name) != "link" || !hasAttr {
+				continue
+			}
+			var rel, href string
+			for {
+				key, val, more := z.TagAttr()
+				switch string(key) {
+				case "rel":
+					rel = strings.ToLower(string(val))
+				case "href":
+					href = strings.TrimSpace(string(val))
+				}
+				if !more {
+					break
+				}
+			}
+			if href == "" || !hasToken(rel, "canonical") {
+				continue
+			}
+			ref, err := url.Parse(href)
+			if err != nil {
+				return ""
+			}
+			if base != nil {
+				ref = base.ResolveReference(ref)
+			}
+			if ref.Scheme != "http" && ref.Scheme != "https" {
+				return ""
+			}
+			return ref.String()
+		}
+	}
+}
+
+// hasToken reports whether the space-separated list contains token.
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
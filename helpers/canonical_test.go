@@ -0,0 +1,44 @@
+package helpers
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	tests := map[string]string{
+		"http://example.com/story":                                "https://example.com/story",
+		"https://www.example.com/story":                           "https://example.com/story",
+		"HTTP://WWW.Example.com:80/story/":                        "https://example.com/story",
+		"https://m.example.com/story#comments":                    "https://example.com/story",
+		"https://example.com/story?utm_source=x&utm_medium=email": "https://example.com/story",
+		"https://example.com/story?ref=home&share=true&amp=1":     "https://example.com/story",
+		"https://example.com/story?cmp=rss&cmpid=nl&fbclid=abc":   "https://example.com/story",
+		"https://example.com/story?outputType=amp":                "https://example.com/story",
+		"https://example.com/amp/story":                           "https://example.com/story",
+		"https://example.com/story/amp/":                          "https://example.com/story",
+		"https://example.com/story.amp.html":                      "https://example.com/story.html",
+		"https://example.com/story?id=42&utm_campaign=daily":      "https://example.com/story?id=42",
+		"https://example.com/story?page=2&id=42":                  "https://example.com/story?id=42&page=2",
+		"https://example.com:8443/story":                          "https://example.com:8443/story",
+		"https://www.example.com/article?id=7&ref=twitter#top":    "https://example.com/article?id=7",
+		"  not a url  ": "not a url",
+	}
+	for in, want := range tests {
+		if got := CanonicalURL(in); got != want {
+			t.Errorf("CanonicalURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCanonicalURLMatchesVariants(t *testing.T) {
+	variants := []string{
+		"http://www.example.com/good-news",
+		"https://example.com/good-news/",
+		"https://www.example.com/good-news?utm_source=feed",
+		"http://example.com/good-news#top",
+	}
+	want := CanonicalURL(variants[0])
+	for _, v := range variants[1:] {
+		if got := CanonicalURL(v); got != want {
+			t.Errorf("CanonicalURL(%q) = %q, want %q like %q", v, got, want, variants[0])
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// checkpoint.go
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Checkpoint names, one per pipeline stage. Candidate pages are saved as candidates-<source>-<page>.json.
+const (
+	checkpointArticles = "articles.json"
+	checkpointRanking  = "ranking.json"
+	checkpointMessage  = "message.json"
+	checkpointSent     = "sent.json"
+)
+
+// Checkpoints saves the output of each completed pipeline stage under the run ID, so that a
+// resumed run (see RunLock) can pick up after the last stage that finished. A nil *Checkpoints
+// does nothing, which is how checkpointing is turned off.
+type Checkpoints struct {
+	Store ArtifactStore
+}
+
+// NewCheckpoints returns the run's checkpoints in cfg.CheckpointDir or under cfg.CheckpointPrefix
+// in the artifact bucket (see NewArtifactStore), or nil if checkpointing is disabled.
+func NewCheckpoints(ctx context.Context, cfg Config, runID string) (*Checkpoints, error) {
+	if !cfg.Checkpoints {
+		return nil, nil
+	}
+	store, err := NewArtifactStore(ctx, cfg, cfg.CheckpointDir, cfg.CheckpointPrefix, runID)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoints{Store: store}, nil
+}
+
+// Load decodes the named checkpoint into v and reports whether it was found. Read and decode
+// errors are logged and treated as a missing checkpoint, so the stage simply runs again.
+func (c *Checkpoints) Load(ctx context.Context, name string, v interface{}) bool {
+	if c == nil {
+		return false
+	}
+	data, err := c.Store.ReadArtifact(ctx, name)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Error reading checkpoint %s: %v\n", name, err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		fmt.Printf("Error decoding checkpoint %s: %v\n", name, err)
+		return false
+	}
+	fmt.Println("Resumed from checkpoint", c.Store.Location(name))
+	return true
+}
+
+// Save writes v as the named checkpoint. Failures are logged; they only cost a rerun of the stage.
+func (c *Checkpoints) Save(ctx context.Context, name string, v interface{}) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("Error encoding checkpoint %s: %v\n", name, err)
+		return
+	}
+	if err := c.Store.WriteArtifact(ctx, name, data, "application/json"); err != nil {
+		fmt.Printf("Error writing checkpoint %s: %v\n", name, err)
+	}
+}
+
+// Sources wraps each source so that fetched pages are checkpointed and re-read on resume
+// instead of being requested again.
+func (c *Checkpoints) Sources(sources []NewsSource) []NewsSource {
+	if c == nil {
+		return sources
+	}
+	wrapped := make([]NewsSource, len(sources))
+	for i, src := range sources {
+		wrapped[i] = &checkpointedSource{NewsSource: src, checkpoints: c}
+	}
+	return wrapped
+}
+
+type checkpointedSource struct {
+	NewsSource
+	checkpoints *Checkpoints
+}
+
+func (s *checkpointedSource) FetchPage(ctx context.Context, from, to time.Time, page int) ([]Article, error) {
+	name := fmt.Sprintf("candidates-%s-%d.json", s.Name(), page)
+	var articles []Article
+	if s.checkpoints.Load(ctx, name, &articles) {
+		return articles, nil
+	}
+	articles, err := s.NewsSource.FetchPage(ctx, from, to, page)
+	if err != nil {
+		return nil, err
+	}
+	s.checkpoints.Save(ctx, name, articles)
+	return articles, nil
+}
+
+// rankingCheckpoint is the saved output of the ranking stage.
+type rankingCheckpoint struct {
+	Ranker string          `json:"ranker"`
+	Ranked []RankedArticle `json:"ranked"`
+}
+
+// sentCheckpoint returns the run as saved after sending. Checkpoints are kept for a long time and
+// the bucket may be shared, so recipient addresses are replaced by their recipientHash; message IDs and errors are
+// kept. A run saved as RunStatusFailed reached only some recipients, and a resume sends to the rest.
+func sentCheckpoint(run DigestRun) DigestRun {
+	deliveries := make([]Delivery, len(run.Deliveries))
+	for i, d := range run.Deliveries {
+		if d.Recipient != "" {
+			d.RecipientHash = recipientHash(run.RunID, d.Recipient)
+			d.Recipient = ""
+		}
+		deliveries[i] = d
+	}
+	run.Deliveries = deliveries
+	return run
+}
+
+// recipientHash identifies a recipient of the run without storing the address.
+func recipientHash(runID, recipient string) string {
+	sum := sha256.Sum256([]byte(runID + "\n" + strings.ToLower(recipient)))
+	return hex.EncodeToString(sum[:])
+}
+
+// delivered returns the successful deliveries of a saved run.
+func delivered(saved DigestRun) []Delivery {
+	var done []Delivery
+	for _, d := range saved.Deliveries {
+		if d.Error == "" {
+			done = append(done, d)
+		}
+	}
+	return done
+}
+
+// deliveredTo returns a skip function for Mailer.SendDigest that reports whether the saved run
+// already reached a recipient.
+func deliveredTo(saved DigestRun) func(recipient string) bool {
+	done := make(map[string]bool)
+	for _, d := range delivered(saved) {
+		done[d.RecipientHash] = true
+	}
+	return func(recipient string) bool {
+		return done[recipientHash(saved.RunID, recipient)]
+	}
+}
+
+// messageCheckpoint is the saved output of the render stage.
+type messageCheckpoint struct {
+	Articles     []ArticleWithContent `json:"articles"`
+	PreSignedURL string               `json:"preSignedUrl"`
+	Email        RenderedEmail        `json:"email"`
+}
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSentCheckpointOmitsRecipients(t *testing.T) {
+	ctx := context.Background()
+	checkpoints := &Checkpoints{Store: &DirArtifacts{Dir: t.TempDir()}}
+	run := DigestRun{
+		RunID:  "run-1",
+		Mailer: MailerSES,
+		Deliveries: []Delivery{
+			{Recipient: "someone@example.com", MessageID: "m-1"},
+			{Recipient: "other@example.com", Error: "throttled"},
+		},
+	}
+	checkpoints.Save(ctx, checkpointSent, sentCheckpoint(run))
+
+	data, err := checkpoints.Store.ReadArtifact(ctx, checkpointSent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "@example.com") {
+		t.Errorf("sent checkpoint contains recipient addresses: %s", data)
+	}
+	var resumed DigestRun
+	if !checkpoints.Load(ctx, checkpointSent, &resumed) {
+		t.Fatal("sent checkpoint not found")
+	}
+	if len(resumed.Deliveries) != 2 || resumed.Deliveries[0].MessageID != "m-1" || resumed.Deliveries[1].Error != "throttled" {
+		t.Errorf("resumed deliveries = %+v", resumed.Deliveries)
+	}
+	if run.Deliveries[0].Recipient != "someone@example.com" {
+		t.Error("sentCheckpoint changed the run's own deliveries")
+	}
+
+	// A resume skips whoever already has the digest and retries the rest.
+	skip := deliveredTo(resumed)
+	if !skip("Someone@example.com") || skip("other@example.com") || skip("new@example.com") {
+		t.Errorf("deliveredTo: someone %v, other %v, new %v; want true, false, false",
+			skip("Someone@example.com"), skip("other@example.com"), skip("new@example.com"))
+	}
+	if done := delivered(resumed); len(done) != 1 || done[0].MessageID != "m-1" {
+		t.Errorf("delivered = %+v, want only m-1", done)
+	}
+}
+
+func TestPendingRecipients(t *testing.T) {
+	subs := []Subscriber{{Email: "a@example.com"}, {Email: "b@example.com"}}
+	if got := pending(subs, nil); len(got) != 2 {
+		t.Errorf("pending without skip = %v", got)
+	}
+	got := pending(subs, func(r string) bool { return r == "a@example.com" })
+	if len(got) != 1 || got[0].Email != "b@example.com" {
+		t.Errorf("pending = %v, want only b@example.com", got)
+	}
+}
+
+func TestArtifactStoreNeverUsesWebsiteBucket(t *testing.T) {
+	cfg := DefaultConfig()
+	store, err := NewArtifactStore(context.Background(), cfg, "", cfg.CheckpointPrefix, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, ok := store.(*DirArtifacts)
+	if !ok {
+		t.Fatalf("store = %T, want a temp directory when no artifact bucket is set", store)
+	}
+	if !strings.HasPrefix(dir.Dir, os.TempDir()) || filepath.Base(dir.Dir) != "run-1" {
+		t.Errorf("dir = %q", dir.Dir)
+	}
+
+	cfg.ArtifactBucket = cfg.BucketName
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "artifactBucket") {
+		t.Errorf("Validate with the website bucket = %v, want an artifactBucket error", err)
+	}
+}
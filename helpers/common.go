@@ -9,13 +9,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 )
 
-// Shared constants
-const (
-	NewsAPIURL           = "https://newsapi.org/v2/everything"
-	TableName            = "PositiveArticles"
-	SnsTopicARNHardcoded = "arn:aws:sns:us-east-2:969666470832:positive_news"
-)
-
 // Shared type definitions
 type NewsResponse struct {
 	Articles []Article `json:"articles"`
@@ -28,27 +21,40 @@ type Article struct {
 	ImageURL    string `json:"urlToImage"`
 }
 
+// ArticleWithContent is a candidate that passed filtering. The ranking fields
+// (Category through Flags) are filled in by SelectTopArticles.
 type ArticleWithContent struct {
-	Title    string
-	URL      string
-	Excerpt  string
-	ImageURL string
+	Title       string
+	URL         string
+	Excerpt     string
+	ImageURL    string
+	Fingerprint uint64
+	Category    string
+	Score       int
+	Confidence  float64
+	Reason      string
+	Flags       []string
 }
 
+// RankedArticle is one entry of a ranker's output. Score is positivity from 0 to 100,
+// Confidence is the ranker's certainty from 0 to 1, and Flags lists content warnings.
 type RankedArticle struct {
-	Rank     int    `json:"rank"`
-	Title    string `json:"title"`
-	URL      string `json:"url"`
-	Category string `json:"category"`
+	Rank       int      `json:"rank"`
+	Title      string   `json:"title"`
+	URL        string   `json:"url"`
+	Category   string   `json:"category"`
+	Score      int      `json:"score"`
+	Confidence float64  `json:"confidence"`
+	Reason     string   `json:"reason"`
+	Flags      []string `json:"flags"`
 }
 
-func LoadAWSConfig(ctx context.Context) (aws.Config, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return cfg, fmt.Errorf("failed to load AWS configuration: %w", err)
-	}
-	return cfg, nil
-}
+// Content flags a ranker may attach to an article.
+const (
+	FlagCommerce = "commerce"
+	FlagSelfHelp = "self-help"
+	FlagPolitics = "politics"
+)
 
 // LoadAWSConfigWithRegion loads the AWS configuration for a specified region.
 func LoadAWSConfigWithRegion(ctx context.Context, region string) (aws.Config, error) {
@@ -58,3 +64,19 @@ func LoadAWSConfigWithRegion(ctx context.Context, region string) (aws.Config, er
 	}
 	return cfg, nil
 }
+
+// uniqueStrings returns values without repeats, keeping the first occurrence of each.
+func uniqueStrings(values []string) []string {
+	if len(values) < 2 {
+		return values
+	}
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,478 @@
+// config.go
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting the pipeline and handlers need. It is loaded once at startup
+// by LoadConfig and passed explicitly to the helpers that use it.
+type Config struct {
+	// AWS resources
+	Region        string `json:"region"`
+	BucketName    string `json:"bucketName"` // the public website bucket
+	LatestNewsKey string `json:"latestNewsKey"`
+	IndexKey      string `json:"indexKey"`
+	ArticlesTable string `json:"articlesTable"`
+	RunsTable     string `json:"runsTable"`
+	// SubscribersTable holds the Subscriber records.
+	SubscribersTable string `json:"subscribersTable"`
+	SNSTopicARN      string `json:"snsTopicArn"`
+	SecretName       string `json:"secretName"`
+	// ArtifactBucket is a private bucket for checkpoints and dry run output; without it they go
+	// to a temporary directory. It must not be the website bucket.
+	ArtifactBucket string `json:"artifactBucket"`
+
+	// News sources
+	NewsAPIURL   string   `json:"newsApiUrl"`
+	NewsQuery    string   `json:"newsQuery"`
+	NewsPageSize int      `json:"newsPageSize"`
+	FeedURLs     []string `json:"feedUrls"`
+	LookbackDays int      `json:"lookbackDays"`
+	MaxPages     int      `json:"maxPages"`
+	UserAgent    string   `json:"userAgent"`
+
+	// Filtering thresholds
+	TargetArticles     int `json:"targetArticles"`
+	MinWords           int `json:"minWords"`
+	ExcerptWords       int `json:"excerptWords"`
+	HistoryDays        int `json:"historyDays"`
+	ExtractConcurrency int `json:"extractConcurrency"`
+	ExtractTimeoutSecs int `json:"extractTimeoutSeconds"`
+
+	// Ranking
+	RankerModel       string   `json:"rankerModel"`
+	RankerTemperature *float32 `json:"rankerTemperature"` // nil leaves the server default in place
+	RankerBaseURL     string   `json:"rankerBaseUrl"`
+	RankerAPIKey      string   `json:"rankerApiKey"`
+	MinScore          int      `json:"minScore"`
+	TopArticles       int      `json:"topArticles"`
+
+	// Email: template files override the built-in ones; WebsiteURL defaults to the bucket's website endpoint.
+	EmailHTMLTemplate string `json:"emailHtmlTemplate"`
+	EmailTextTemplate string `json:"emailTextTemplate"`
+	WebsiteURL        string `json:"websiteUrl"`
+
+	// Delivery: Mailer is "sns" (one plain-text message to the topic), or "ses" or "smtp" (one
+	// multipart message per active subscriber from EmailFrom). EmailRecipients replaces the
+	// subscriber list for ses and smtp. UnsubscribeURL is the GET /unsubscribe route of the
+	// Function URL; each recipient's link adds a signed token (see ConfirmSecret).
+	// SNSSubscriptions keeps the SNS topic in step with subscribe and unsubscribe requests; new
+	// subscribers are only added to the topic with the sns mailer.
+	SNSSubscriptions bool     `json:"snsSubscriptions"`
+	Mailer           string   `json:"mailer"`
+	EmailFrom        string   `json:"emailFrom"`
+	EmailRecipients  []string `json:"emailRecipients"`
+	UnsubscribeURL   string   `json:"unsubscribeUrl"`
+
+	// Double opt-in for the ses and smtp mailers: confirmation links point at ConfirmURL (by
+	// default the /confirm route of the Function URL that received the request), carry a token
+	// signed with ConfirmSecret (or CONFIRM_SECRET in the Secrets Manager secret) and expire, with
+	// the pending subscriber, after ConfirmExpiryHours.
+	ConfirmURL         string `json:"confirmUrl"`
+	ConfirmSecret      string `json:"confirmSecret"`
+	ConfirmExpiryHours int    `json:"confirmExpiryHours"`
+
+	// SMTP server for the smtp mailer. SMTPTLS is "starttls", "implicit" or "none"; SMTPAuth is
+	// "plain" or "login" and only used when SMTPUsername is set.
+	SMTPHost     string `json:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"smtpPassword"`
+	SMTPTLS      string `json:"smtpTls"`
+	SMTPAuth     string `json:"smtpAuth"`
+
+	// Storage
+	StoreBackend string `json:"storeBackend"`
+	StorePath    string `json:"storePath"`
+
+	// Dry run: fetch, filter and rank for real, but write the results to DryRunDir (if set)
+	// or under DryRunPrefix in ArtifactBucket instead of publishing and sending.
+	DryRun       bool   `json:"dryRun"`
+	DryRunDir    string `json:"dryRunDir"`
+	DryRunPrefix string `json:"dryRunPrefix"`
+
+	// Run lock: ForceRun replaces a day's completed run instead of skipping it. RunLockLeaseMins
+	// applies to runs without a deadline; Lambda invocations hold the lock until just past theirs.
+	ForceRun         bool `json:"forceRun"`
+	RunLockLeaseMins int  `json:"runLockLeaseMinutes"`
+
+	// Checkpoints: each completed stage is saved to CheckpointDir (if set) or under
+	// CheckpointPrefix in ArtifactBucket, keyed by run ID, so a resumed run can skip it.
+	Checkpoints      bool   `json:"checkpoints"`
+	CheckpointDir    string `json:"checkpointDir"`
+	CheckpointPrefix string `json:"checkpointPrefix"`
+}
+
+// DefaultConfig returns the settings the service ran with before they were configurable.
+func DefaultConfig() Config {
+	return Config{
+		Region:        "us-east-2",
+		BucketName:    "pk-positive-news",
+		LatestNewsKey: "latest_news.json",
+		IndexKey:      "index.html",
+		ArticlesTable: "PositiveArticles",
+		RunsTable:     "PositiveDigestRuns",
+
+		SubscribersTable: "PositiveSubscribers",
+		SNSTopicARN:      "arn:aws:sns:us-east-2:969666470832:positive_news",
+		SecretName:       "positiveNews_openai_newsapi_keys",
+
+		NewsAPIURL:   "https://newsapi.org/v2/everything",
+		NewsQuery:    DefaultNewsQuery,
+		NewsPageSize: 50,
+		FeedURLs:     DefaultFeedURLs,
+		LookbackDays: 7,
+		MaxPages:     3,
+		UserAgent:    DefaultUserAgent,
+
+		TargetArticles:     30,
+		MinWords:           150,
+		ExcerptWords:       50,
+		HistoryDays:        30,
+		ExtractConcurrency: 8,
+		ExtractTimeoutSecs: 15,
+
+		Mailer:           MailerSNS,
+		SNSSubscriptions: true,
+
+		ConfirmExpiryHours: 48,
+		SMTPPort:           587,
+		SMTPTLS:            SMTPTLSStartTLS,
+		SMTPAuth:           SMTPAuthPlain,
+
+		RankerModel: DefaultOpenAIModel,
+		MinScore:    DefaultMinScore,
+		TopArticles: 10,
+
+		StoreBackend: StoreBackendDynamoDB,
+		StorePath:    DefaultFileStorePath,
+
+		DryRunPrefix: "dry-run/",
+
+		RunLockLeaseMins: int(DefaultRunLockLease / time.Minute),
+
+		Checkpoints:      true,
+		CheckpointPrefix: "checkpoints/",
+	}
+}
+
+// LoadConfig builds the configuration from defaults, then the JSON or YAML file named by
+// CONFIG_FILE (if set), then environment variables, and validates the result.
+func LoadConfig() (Config, error) {
+	return loadConfig(os.LookupEnv)
+}
+
+// loadConfig is LoadConfig reading environment variables through lookup.
+func loadConfig(lookup func(string) (string, bool)) (Config, error) {
+	cfg := DefaultConfig()
+	if path, _ := lookup("CONFIG_FILE"); path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return cfg, err
+		}
+	}
+	if err := cfg.applyEnv(lookup); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// applyFile overrides fields with the keys set in a config file. Files ending in .yaml or .yml
+// are YAML, anything else is JSON; both use the json tag names.
+func (c *Config) applyFile(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var doc interface{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if doc == nil {
+			return nil
+		}
+		if raw, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(raw, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides fields from environment variables that are set.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	str := map[string]*string{
+		"AWS_REGION":                  &c.Region,
+		"S3_BUCKET":                   &c.BucketName,
+		"ARTIFACT_BUCKET":             &c.ArtifactBucket,
+		"S3_LATEST_NEWS_KEY":          &c.LatestNewsKey,
+		"S3_INDEX_KEY":                &c.IndexKey,
+		"ARTICLES_TABLE":              &c.ArticlesTable,
+		"RUNS_TABLE":                  &c.RunsTable,
+		"SUBSCRIBERS_TABLE":           &c.SubscribersTable,
+		"SNS_TOPIC_ARN":               &c.SNSTopicARN,
+		"SECRETS_MANAGER_SECRET_NAME": &c.SecretName,
+		"NEWS_API_URL":                &c.NewsAPIURL,
+		"NEWS_QUERY":                  &c.NewsQuery,
+		"HTTP_USER_AGENT":             &c.UserAgent,
+		"RANKER_MODEL":                &c.RankerModel,
+		"RANKER_BASE_URL":             &c.RankerBaseURL,
+		"RANKER_API_KEY":              &c.RankerAPIKey,
+		"EMAIL_HTML_TEMPLATE":         &c.EmailHTMLTemplate,
+		"EMAIL_TEXT_TEMPLATE":         &c.EmailTextTemplate,
+		"WEBSITE_URL":                 &c.WebsiteURL,
+		"MAILER":                      &c.Mailer,
+		"EMAIL_FROM":                  &c.EmailFrom,
+		"UNSUBSCRIBE_URL":             &c.UnsubscribeURL,
+		"SMTP_HOST":                   &c.SMTPHost,
+		"SMTP_USERNAME":               &c.SMTPUsername,
+		"SMTP_PASSWORD":               &c.SMTPPassword,
+		"CONFIRM_URL":                 &c.ConfirmURL,
+		"CONFIRM_SECRET":              &c.ConfirmSecret,
+		"SMTP_TLS":                    &c.SMTPTLS,
+		"SMTP_AUTH":                   &c.SMTPAuth,
+		"ARTICLE_STORE":               &c.StoreBackend,
+		"ARTICLE_STORE_PATH":          &c.StorePath,
+		"DRY_RUN_DIR":                 &c.DryRunDir,
+		"DRY_RUN_S3_PREFIX":           &c.DryRunPrefix,
+		"CHECKPOINT_DIR":              &c.CheckpointDir,
+		"CHECKPOINT_S3_PREFIX":        &c.CheckpointPrefix,
+	}
+	ints := map[string]*int{
+		"NEWS_PAGE_SIZE":       &c.NewsPageSize,
+		"NEWS_LOOKBACK_DAYS":   &c.LookbackDays,
+		"NEWS_MAX_PAGES":       &c.MaxPages,
+		"TARGET_ARTICLES":      &c.TargetArticles,
+		"MIN_WORDS":            &c.MinWords,
+		"EXCERPT_WORDS":        &c.ExcerptWords,
+		"HISTORY_DAYS":         &c.HistoryDays,
+		"EXTRACT_CONCURRENCY":  &c.ExtractConcurrency,
+		"EXTRACT_TIMEOUT_SECS": &c.ExtractTimeoutSecs,
+		"MIN_POSITIVITY_SCORE": &c.MinScore,
+		"TOP_ARTICLES":         &c.TopArticles,
+		"RUN_LOCK_LEASE_MINS":  &c.RunLockLeaseMins,
+		"SMTP_PORT":            &c.SMTPPort,
+		"CONFIRM_EXPIRY_HOURS": &c.ConfirmExpiryHours,
+	}
+	bools := map[string]*bool{
+		"DRY_RUN":     &c.DryRun,
+		"FORCE_RUN":   &c.ForceRun,
+		"CHECKPOINTS": &c.Checkpoints,
+
+		"SNS_SUBSCRIPTIONS": &c.SNSSubscriptions,
+	}
+	var errs []error
+	for name, field := range str {
+		if v, ok := lookup(name); ok && v != "" {
+			*field = v
+		}
+	}
+	for name, field := range ints {
+		if v, ok := lookup(name); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", name, v))
+				continue
+			}
+			*field = n
+		}
+	}
+	for name, field := range bools {
+		if v, ok := lookup(name); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a boolean", name, v))
+				continue
+			}
+			*field = b
+		}
+	}
+	if v, ok := lookup("NEWS_FEED_URLS"); ok && v != "" {
+		c.FeedURLs = ParseFeedURLs(v)
+	}
+	if v, ok := lookup("EMAIL_RECIPIENTS"); ok && v != "" {
+		c.EmailRecipients = nil
+		for _, addr := range strings.Split(v, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				c.EmailRecipients = append(c.EmailRecipients, addr)
+			}
+		}
+	}
+	if v, ok := lookup("RANKER_TEMPERATURE"); ok && v != "" {
+		t, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("RANKER_TEMPERATURE: %q is not a number", v))
+		} else {
+			temperature := float32(t)
+			c.RankerTemperature = &temperature
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c Config) Validate() error {
+	var problems []string
+	required := map[string]string{
+		"region":        c.Region,
+		"bucketName":    c.BucketName,
+		"latestNewsKey": c.LatestNewsKey,
+		"indexKey":      c.IndexKey,
+		"articlesTable": c.ArticlesTable,
+		"runsTable":     c.RunsTable,
+
+		"subscribersTable": c.SubscribersTable,
+		"snsTopicArn":      c.SNSTopicARN,
+		"secretName":       c.SecretName,
+		"newsQuery":        c.NewsQuery,
+		"rankerModel":      c.RankerModel,
+	}
+	for name, value := range required {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+	positive := map[string]int{
+		"newsPageSize":          c.NewsPageSize,
+		"lookbackDays":          c.LookbackDays,
+		"maxPages":              c.MaxPages,
+		"targetArticles":        c.TargetArticles,
+		"excerptWords":          c.ExcerptWords,
+		"historyDays":           c.HistoryDays,
+		"extractConcurrency":    c.ExtractConcurrency,
+		"extractTimeoutSeconds": c.ExtractTimeoutSecs,
+		"topArticles":           c.TopArticles,
+		"runLockLeaseMinutes":   c.RunLockLeaseMins,
+		"confirmExpiryHours":    c.ConfirmExpiryHours,
+	}
+	for name, value := range positive {
+		if value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be positive, got %d", name, value))
+		}
+	}
+	if c.NewsPageSize > 100 {
+		problems = append(problems, fmt.Sprintf("newsPageSize must be at most 100, got %d", c.NewsPageSize))
+	}
+	if c.MinWords < c.ExcerptWords {
+		problems = append(problems, fmt.Sprintf("minWords (%d) must be at least excerptWords (%d)", c.MinWords, c.ExcerptWords))
+	}
+	if c.MinScore < 0 || c.MinScore > 100 {
+		problems = append(problems, fmt.Sprintf("minScore must be between 0 and 100, got %d", c.MinScore))
+	}
+	if t := c.RankerTemperature; t != nil && (*t < 0 || *t > 2) {
+		problems = append(problems, fmt.Sprintf("rankerTemperature must be between 0 and 2, got %g", *t))
+	}
+	for name, raw := range map[string]string{"newsApiUrl": c.NewsAPIURL, "rankerBaseUrl": c.RankerBaseURL, "websiteUrl": c.WebsiteURL, "confirmUrl": c.ConfirmURL, "unsubscribeUrl": c.UnsubscribeURL} {
+		if raw == "" && name != "newsApiUrl" {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s %q is not an absolute URL", name, raw))
+		}
+	}
+	if strings.Contains(c.UnsubscribeURL, "{email}") {
+		problems = append(problems, "unsubscribeUrl no longer takes an {email} placeholder; links carry a signed token instead")
+	}
+	if c.StoreBackend != StoreBackendDynamoDB && c.StoreBackend != StoreBackendFile {
+		problems = append(problems, fmt.Sprintf("storeBackend must be %q or %q, got %q", StoreBackendDynamoDB, StoreBackendFile, c.StoreBackend))
+	}
+	switch c.Mailer {
+	case MailerSNS:
+		if !c.SNSSubscriptions {
+			problems = append(problems, "the sns mailer needs snsSubscriptions, or nobody would receive the digest")
+		}
+	case MailerSES, MailerSMTP:
+		if c.EmailFrom == "" {
+			problems = append(problems, fmt.Sprintf("emailFrom is required for the %s mailer", c.Mailer))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("mailer must be %q, %q or %q, got %q", MailerSNS, MailerSES, MailerSMTP, c.Mailer))
+	}
+	if c.Mailer == MailerSMTP {
+		if c.SMTPHost == "" {
+			problems = append(problems, "smtpHost is required for the smtp mailer")
+		}
+		if c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+			problems = append(problems, fmt.Sprintf("smtpPort must be between 1 and 65535, got %d", c.SMTPPort))
+		}
+		if c.SMTPTLS != SMTPTLSStartTLS && c.SMTPTLS != SMTPTLSImplicit && c.SMTPTLS != SMTPTLSNone {
+			problems = append(problems, fmt.Sprintf("smtpTls must be %q, %q or %q, got %q", SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone, c.SMTPTLS))
+		}
+		if c.SMTPAuth != SMTPAuthPlain && c.SMTPAuth != SMTPAuthLogin {
+			problems = append(problems, fmt.Sprintf("smtpAuth must be %q or %q, got %q", SMTPAuthPlain, SMTPAuthLogin, c.SMTPAuth))
+		}
+	}
+	if c.ArtifactBucket != "" && c.ArtifactBucket == c.BucketName {
+		problems = append(problems, fmt.Sprintf("artifactBucket must be a private bucket, not the website bucket %q", c.BucketName))
+	}
+	if c.DryRun && c.DryRunDir == "" && strings.Trim(c.DryRunPrefix, "/") == "" {
+		problems = append(problems, "dryRun needs dryRunDir or a non-empty dryRunPrefix")
+	}
+	if c.Checkpoints && c.CheckpointDir == "" && strings.Trim(c.CheckpointPrefix, "/") == "" {
+		problems = append(problems, "checkpoints needs checkpointDir or a non-empty checkpointPrefix")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
+// HistoryCutoff returns the time after which a stored article counts as recently sent.
+func (c Config) HistoryCutoff() time.Time {
+	return historyCutoff(c.HistoryDays)
+}
+
+func historyCutoff(days int) time.Time {
+	return time.Now().UTC().AddDate(0, 0, -days)
+}
+
+// ExtractOptions returns the content download limits.
+func (c Config) ExtractOptions() ExtractOptions {
+	return ExtractOptions{
+		Concurrency: c.ExtractConcurrency,
+		Timeout:     time.Duration(c.ExtractTimeoutSecs) * time.Second,
+		Client:      c.HTTPClient(),
+	}
+}
+
+// RunLockLease returns how long a run holds the daily lock.
+func (c Config) RunLockLease() time.Duration {
+	return time.Duration(c.RunLockLeaseMins) * time.Minute
+}
+
+// ConfirmExpiry returns how long a confirmation link, and the pending subscriber, stays valid.
+func (c Config) ConfirmExpiry() time.Duration {
+	return time.Duration(c.ConfirmExpiryHours) * time.Hour
+}
+
+// Website returns the link to the website used in emails.
+func (c Config) Website() string {
+	if c.WebsiteURL != "" {
+		return c.WebsiteURL
+	}
+	return fmt.Sprintf("http://%s.s3-website.%s.amazonaws.com/", c.BucketName, c.Region)
+}
+
+// HTTPClient returns a client sending the configured User-Agent.
+func (c Config) HTTPClient() *HTTPClient {
+	return NewHTTPClient(c.UserAgent)
+}
@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// envMap is an applyEnv lookup backed by a map.
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{"runsTable": "FileRuns", "topArticles": 7, "newsQuery": "file query", "feedUrls": ["https://example.com/feed"]}`,
+		"config.yaml": "runsTable: FileRuns\ntopArticles: 7\nnewsQuery: file query\nfeedUrls:\n  - https://example.com/feed\n",
+		"config.yml":  "# comments are fine\nrunsTable: FileRuns\ntopArticles: 7\nnewsQuery: 'file query'\nfeedUrls: [https://example.com/feed]\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			env := map[string]string{
+				"CONFIG_FILE":  writeConfigFile(t, name, content),
+				"NEWS_QUERY":   "env query",
+				"TOP_ARTICLES": "",
+			}
+			cfg, err := loadConfig(envMap(env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defaults := DefaultConfig()
+			if cfg.ArticlesTable != defaults.ArticlesTable {
+				t.Errorf("articlesTable = %q, want the default %q", cfg.ArticlesTable, defaults.ArticlesTable)
+			}
+			if cfg.RunsTable != "FileRuns" || cfg.TopArticles != 7 || len(cfg.FeedURLs) != 1 {
+				t.Errorf("file settings not applied: runsTable %q, topArticles %d, feedUrls %v", cfg.RunsTable, cfg.TopArticles, cfg.FeedURLs)
+			}
+			if cfg.NewsQuery != "env query" {
+				t.Errorf("newsQuery = %q, want the environment to win", cfg.NewsQuery)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		env  map[string]string
+		want string
+	}{
+		"missing file":   {map[string]string{"CONFIG_FILE": filepath.Join(t.TempDir(), "missing.json")}, "failed to read config file"},
+		"bad json":       {map[string]string{"CONFIG_FILE": writeConfigFile(t, "bad.json", `{"topArticles": "ten"}`)}, "failed to parse config file"},
+		"bad yaml":       {map[string]string{"CONFIG_FILE": writeConfigFile(t, "bad.yaml", "topArticles: [1")}, "failed to parse config file"},
+		"invalid values": {map[string]string{"CONFIG_FILE": writeConfigFile(t, "zero.yaml", "topArticles: 0\n")}, "topArticles must be positive"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadConfig(envMap(tt.env)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadConfig = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg := DefaultConfig()
+	err := cfg.applyEnv(envMap(map[string]string{
+		"S3_BUCKET":          "other-bucket",
+		"ARTICLES_TABLE":     "", // empty values are ignored
+		"TARGET_ARTICLES":    "12",
+		"DRY_RUN":            "true",
+		"CHECKPOINTS":        "0",
+		"NEWS_FEED_URLS":     "https://a.example/feed, https://b.example/feed",
+		"EMAIL_RECIPIENTS":   " a@example.com, ,b@example.com ",
+		"RANKER_TEMPERATURE": "0.5",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BucketName != "other-bucket" || cfg.ArticlesTable != DefaultConfig().ArticlesTable {
+		t.Errorf("strings: bucket %q, articles table %q", cfg.BucketName, cfg.ArticlesTable)
+	}
+	if cfg.TargetArticles != 12 || !cfg.DryRun || cfg.Checkpoints {
+		t.Errorf("numbers and booleans: targetArticles %d, dryRun %v, checkpoints %v", cfg.TargetArticles, cfg.DryRun, cfg.Checkpoints)
+	}
+	if len(cfg.FeedURLs) != 2 || strings.Join(cfg.EmailRecipients, " ") != "a@example.com b@example.com" {
+		t.Errorf("lists: feeds %v, recipients %v", cfg.FeedURLs, cfg.EmailRecipients)
+	}
+	if cfg.RankerTemperature == nil || *cfg.RankerTemperature != 0.5 {
+		t.Errorf("rankerTemperature = %v, want 0.5", cfg.RankerTemperature)
+	}
+
+	cfg = DefaultConfig()
+	err = cfg.applyEnv(envMap(map[string]string{
+		"TARGET_ARTICLES":    "many",
+		"DRY_RUN":            "perhaps",
+		"RANKER_TEMPERATURE": "warm",
+	}))
+	for _, want := range []string{"TARGET_ARTICLES", "DRY_RUN", "RANKER_TEMPERATURE"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("applyEnv error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("DefaultConfig is invalid: %v", err)
+	}
+	tests := map[string]struct {
+		change func(c *Config)
+		want   string
+	}{
+		"required":             {func(c *Config) { c.Region = " " }, "region is required"},
+		"positive":             {func(c *Config) { c.HistoryDays = 0 }, "historyDays must be positive"},
+		"page size":            {func(c *Config) { c.NewsPageSize = 101 }, "newsPageSize must be at most 100"},
+		"min words":            {func(c *Config) { c.MinWords = c.ExcerptWords - 1 }, "minWords"},
+		"min score":            {func(c *Config) { c.MinScore = 101 }, "minScore must be between 0 and 100"},
+		"temperature":          {func(c *Config) { t := float32(3); c.RankerTemperature = &t }, "rankerTemperature must be between 0 and 2"},
+		"relative url":         {func(c *Config) { c.WebsiteURL = "/news" }, "websiteUrl"},
+		"missing news api url": {func(c *Config) { c.NewsAPIURL = "" }, "newsApiUrl"},
+		"unsubscribe email":    {func(c *Config) { c.UnsubscribeURL = "https://example.com/u?e={email}" }, "{email}"},
+		"store backend":        {func(c *Config) { c.StoreBackend = "sqlite" }, "storeBackend"},
+		"mailer":               {func(c *Config) { c.Mailer = "pigeon" }, "mailer must be"},
+		"sns without topic":    {func(c *Config) { c.Mailer, c.SNSSubscriptions = MailerSNS, false }, "needs snsSubscriptions"},
+		"ses without from":     {func(c *Config) { c.Mailer, c.EmailFrom = MailerSES, "" }, "emailFrom is required"},
+		"smtp host":            {func(c *Config) { c.Mailer, c.EmailFrom = MailerSMTP, "a@example.com" }, "smtpHost is required"},
+		"smtp port":            {func(c *Config) { c.Mailer, c.EmailFrom, c.SMTPPort = MailerSMTP, "a@example.com", 70000 }, "smtpPort"},
+		"smtp tls":             {func(c *Config) { c.Mailer, c.EmailFrom, c.SMTPTLS = MailerSMTP, "a@example.com", "ssl" }, "smtpTls"},
+		"smtp auth":            {func(c *Config) { c.Mailer, c.EmailFrom, c.SMTPAuth = MailerSMTP, "a@example.com", "cram" }, "smtpAuth"},
+		"artifact bucket":      {func(c *Config) { c.ArtifactBucket = c.BucketName }, "artifactBucket must be a private bucket"},
+		"dry run prefix":       {func(c *Config) { c.DryRun, c.DryRunPrefix = true, "/" }, "dryRun needs"},
+		"checkpoint prefix":    {func(c *Config) { c.Checkpoints, c.CheckpointPrefix = true, "" }, "checkpoints needs"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.change(&cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
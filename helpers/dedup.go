@@ -0,0 +1,90 @@
+// dedup.go
+package helpers
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// NearDuplicateDistance is the largest SimHash Hamming distance at which two articles are treated as the same story.
+const NearDuplicateDistance = 10
+
+// Fingerprint returns a 64-bit SimHash of the normalized title and excerpt.
+// Syndicated copies of the same story produce fingerprints a few bits apart.
+func Fingerprint(title, excerpt string) uint64 {
+	tokens := normalizeTokens(title + " " + excerpt)
+	if len(tokens) == 0 {
+		return 0
+	}
+	var weights [64]int
+	addFeature := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	if len(tokens) == 1 {
+		addFeature(tokens[0])
+	}
+	for i := 0; i+1 < len(tokens); i++ {
+		addFeature(tokens[i] + " " + tokens[i+1])
+	}
+	var fp uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fp |= 1 << uint(bit)
+		}
+	}
+	return fp
+}
+
+// IsNearDuplicate reports whether two fingerprints are within NearDuplicateDistance bits.
+func IsNearDuplicate(a, b uint64) bool {
+	return bits.OnesCount64(a^b) <= NearDuplicateDistance
+}
+
+// MatchesAny reports whether fp is a near-duplicate of any fingerprint in history.
+func MatchesAny(fp uint64, history []uint64) bool {
+	for _, h := range history {
+		if IsNearDuplicate(fp, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// betterRepresentative reports whether a should replace b as the representative of their cluster.
+// An article with an image wins; otherwise the earlier (higher-relevance) article is kept.
+func betterRepresentative(a, b ArticleWithContent) bool {
+	return a.ImageURL != "" && b.ImageURL == ""
+}
+
+// stopwords are dropped before fingerprinting so that filler words don't dominate short texts.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "has": true, "have": true, "in": true, "is": true, "it": true, "its": true,
+	"of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"were": true, "will": true, "with": true,
+}
+
+// normalizeTokens lower-cases text, strips punctuation and drops stopwords.
+func normalizeTokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if !stopwords[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,137 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	treesTitle     = "Town plants ten thousand trees"
+	treesText      = "Volunteers in the valley town planted ten thousand native trees over a single weekend, restoring a hillside that burned two summers ago and giving local schools a new outdoor classroom."
+	treesCopy      = "Volunteers in the valley town planted ten thousand native trees over one weekend, restoring a hillside that burned two summers ago and giving local schools a new outdoor classroom!"
+	gardenTitle    = "Flower garden replaces car park"
+	gardenText     = "A disused car park in the city centre has become a community flower garden where neighbours now meet for lunch, swap seedlings and run free workshops for children."
+	treesCopyTitle = "TOWN PLANTS TEN THOUSAND TREES:"
+)
+
+func TestFingerprintNearDuplicates(t *testing.T) {
+	original := Fingerprint(treesTitle, treesText)
+	if original == 0 {
+		t.Fatal("Fingerprint of real text = 0")
+	}
+	if got := Fingerprint(treesTitle, treesText); got != original {
+		t.Errorf("Fingerprint is not stable: %x then %x", original, got)
+	}
+	// A syndicated copy with different case, punctuation and one changed word.
+	syndicated := Fingerprint(treesCopyTitle, treesCopy)
+	if d := bits.OnesCount64(original ^ syndicated); d > NearDuplicateDistance {
+		t.Errorf("syndicated copy is %d bits away, want at most %d", d, NearDuplicateDistance)
+	}
+	if !IsNearDuplicate(original, syndicated) {
+		t.Error("IsNearDuplicate(original, syndicated copy) = false")
+	}
+	different := Fingerprint(gardenTitle, gardenText)
+	if d := bits.OnesCount64(original ^ different); d <= 2*NearDuplicateDistance {
+		t.Errorf("different story is only %d bits away", d)
+	}
+	if IsNearDuplicate(original, different) {
+		t.Error("IsNearDuplicate(original, different story) = true")
+	}
+	if fp := Fingerprint("The", "and of a"); fp != 0 {
+		t.Errorf("Fingerprint of stopwords only = %x, want 0", fp)
+	}
+}
+
+func TestNearDuplicateThreshold(t *testing.T) {
+	const fp uint64 = 0xdeadbeefcafef00d
+	atLimit := fp ^ (1<<NearDuplicateDistance - 1)
+	pastLimit := fp ^ (1<<(NearDuplicateDistance+1) - 1)
+	if !IsNearDuplicate(fp, atLimit) {
+		t.Errorf("%d bits apart is not a near-duplicate", NearDuplicateDistance)
+	}
+	if IsNearDuplicate(fp, pastLimit) {
+		t.Errorf("%d bits apart is a near-duplicate", NearDuplicateDistance+1)
+	}
+	if !MatchesAny(fp, []uint64{0, atLimit}) || MatchesAny(fp, []uint64{pastLimit}) || MatchesAny(fp, nil) {
+		t.Error("MatchesAny does not follow IsNearDuplicate")
+	}
+}
+
+func TestBetterRepresentative(t *testing.T) {
+	withImage := ArticleWithContent{ImageURL: "https://example.com/a.jpg"}
+	var plain ArticleWithContent
+	tests := []struct {
+		a, b ArticleWithContent
+		want bool
+	}{
+		{withImage, plain, true},
+		{plain, withImage, false},
+		{plain, plain, false},
+		{withImage, withImage, false},
+	}
+	for _, tt := range tests {
+		if got := betterRepresentative(tt.a, tt.b); got != tt.want {
+			t.Errorf("betterRepresentative(image %q, image %q) = %v, want %v", tt.a.ImageURL, tt.b.ImageURL, got, tt.want)
+		}
+	}
+}
+
+func TestAccumulateValidArticlesKeepsOneRepresentative(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	pages := map[string]string{
+		"/trees":        treesText,
+		"/trees-copy":   treesCopy,
+		"/trees-again":  treesText,
+		"/garden":       gardenText,
+		"/garden-reuse": gardenText,
+	}
+	for path, text := range pages {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, articlePage("Story", "", text))
+		})
+	}
+
+	cfg := DefaultConfig()
+	cfg.TargetArticles = 5
+	cfg.MaxPages = 1
+	cfg.MinWords = 100
+	cfg.ExcerptWords = 40
+	src := staticSource{
+		{Title: treesTitle, URL: srv.URL + "/trees"},
+		{Title: gardenTitle, URL: srv.URL + "/garden", ImageURL: srv.URL + "/garden.jpg"},
+		// The copy has an image, so it replaces the first article in its place.
+		{Title: treesCopyTitle, URL: srv.URL + "/trees-copy", ImageURL: srv.URL + "/trees.jpg"},
+		// Neither of these beats the representative already chosen.
+		{Title: treesTitle, URL: srv.URL + "/trees-again"},
+		{Title: gardenTitle, URL: srv.URL + "/garden-reuse", ImageURL: srv.URL + "/other.jpg"},
+	}
+	got, err := AccumulateValidArticles(context.Background(), cfg, []NewsSource{src}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{srv.URL + "/trees-copy", srv.URL + "/garden"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d articles (%v), want %v", len(got), got, want)
+	}
+	for i, art := range got {
+		if art.URL != want[i] {
+			t.Errorf("article %d = %s, want %s", i, art.URL, want[i])
+		}
+	}
+
+	// A near-duplicate of a recently sent story is dropped entirely.
+	recent := []uint64{Fingerprint(gardenTitle, gardenText)}
+	got, err = AccumulateValidArticles(context.Background(), cfg, []NewsSource{src}, nil, recent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].URL != srv.URL+"/trees-copy" {
+		t.Errorf("with the garden story recently sent got %v, want only the trees copy", got)
+	}
+}
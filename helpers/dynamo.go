@@ -3,7 +3,10 @@ package helpers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -11,55 +14,610 @@ import (
 	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-// getRecentArticleURLs retrieves URLs of articles stored in DynamoDB within the last month.
-func GetRecentArticleURLs(ctx context.Context) (map[string]bool, error) {
-	cfg, _ := LoadAWSConfig(ctx)
+// StoredDateIndex is the GSI on the articles table that buckets items by day.
+// Partition key StoredDate (YYYY-MM-DD), sort key StoredAt (RFC 3339).
+const StoredDateIndex = "StoredDate-StoredAt-index"
 
-	ddbClient := ddb.NewFromConfig(cfg)
-	oneMonthAgo := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
-	input := &ddb.ScanInput{
-		TableName:        aws.String(TableName),
-		FilterExpression: aws.String("StoredAt >= :date"),
-		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
-			":date": &ddbTypes.AttributeValueMemberS{Value: oneMonthAgo},
-		},
+// RunDateIndex is the GSI on the runs table keyed by RunDate (YYYY-MM-DD) and StartedAt.
+const RunDateIndex = "RunDate-StartedAt-index"
+
+// SubscriberStatusIndex is the GSI on the subscribers table keyed by Status and CreatedAt.
+const SubscriberStatusIndex = "Status-CreatedAt-index"
+
+// DynamoAPI is the part of the DynamoDB client the store uses; *dynamodb.Client implements it.
+type DynamoAPI interface {
+	ddb.QueryAPIClient
+	GetItem(ctx context.Context, params *ddb.GetItemInput, optFns ...func(*ddb.Options)) (*ddb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *ddb.PutItemInput, optFns ...func(*ddb.Options)) (*ddb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *ddb.UpdateItemInput, optFns ...func(*ddb.Options)) (*ddb.UpdateItemOutput, error)
+	BatchGetItem(ctx context.Context, params *ddb.BatchGetItemInput, optFns ...func(*ddb.Options)) (*ddb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *ddb.BatchWriteItemInput, optFns ...func(*ddb.Options)) (*ddb.BatchWriteItemOutput, error)
+}
+
+// DynamoStore is the DynamoDB ArticleStore and SubscriberStore.
+type DynamoStore struct {
+	Client           DynamoAPI
+	ArticlesTable    string
+	RunsTable        string
+	SubscribersTable string
+	HistoryDays      int
+}
+
+// NewDynamoStore returns a DynamoStore for the configured tables and region.
+func NewDynamoStore(ctx context.Context, cfg Config) (*DynamoStore, error) {
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	return &DynamoStore{
+		Client:           ddb.NewFromConfig(awsCfg),
+		ArticlesTable:    cfg.ArticlesTable,
+		RunsTable:        cfg.RunsTable,
+		SubscribersTable: cfg.SubscribersTable,
+		HistoryDays:      cfg.HistoryDays,
+	}, nil
+}
+
+// batchWriteLimit is the maximum number of requests per BatchWriteItem call.
+const batchWriteLimit = 25
+
+// maxBatchAttempts bounds retries of unprocessed BatchGetItem/BatchWriteItem entries.
+const maxBatchAttempts = 6
+
+// StoreArticles saves the selected articles to the articles table with BatchWriteItem, in
+// chunks of batchWriteLimit. BatchWriteItem cannot carry a condition, so the URLs are looked up
+// with BatchGetItem first: an article is written if its URL is not in the table yet or was last
+// stored before the history window, so a story that goes out again is counted as recently sent
+// from now on, while entries still inside the window are left untouched. Unprocessed items are
+// retried with backoff, and every failure is returned joined into a single error.
+func (s *DynamoStore) StoreArticles(ctx context.Context, runID string, articles []ArticleWithContent) error {
+	storedAt := time.Now().UTC()
+	cutoff := historyCutoff(s.HistoryDays).Format(time.RFC3339)
+
+	urls := make([]string, 0, len(articles))
+	for _, art := range articles {
+		urls = append(urls, CanonicalURL(art.URL))
+	}
+	existing, err := s.batchGetArticles(ctx, urls)
+	if err != nil {
+		return fmt.Errorf("failed to check for existing articles: %w", err)
+	}
+	fresh := make(map[string]bool, len(existing))
+	for _, item := range existing {
+		if stringAttr(item, "StoredAt") >= cutoff {
+			fresh[stringAttr(item, "url")] = true
+		}
+	}
+
+	var writes []ddbTypes.WriteRequest
+	for i, art := range articles {
+		url := urls[i]
+		if fresh[url] {
+			fmt.Printf("Article stored within the last %d days, not overwriting: %s\n", s.HistoryDays, url)
+			continue
+		}
+		fresh[url] = true // a batch may not put the same key twice
+		writes = append(writes, ddbTypes.WriteRequest{PutRequest: &ddbTypes.PutRequest{Item: articleItem(runID, art, storedAt)}})
+	}
+
+	var errs []error
+	for start := 0; start < len(writes); start += batchWriteLimit {
+		end := start + batchWriteLimit
+		if end > len(writes) {
+			end = len(writes)
+		}
+		if err := s.batchWrite(ctx, writes[start:end]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Printf("Stored %d articles\n", end-start)
+	}
+	return errors.Join(errs...)
+}
+
+// batchWrite sends one BatchWriteItem call and retries whatever comes back unprocessed.
+func (s *DynamoStore) batchWrite(ctx context.Context, writes []ddbTypes.WriteRequest) error {
+	request := map[string][]ddbTypes.WriteRequest{s.ArticlesTable: writes}
+	for attempt := 0; len(request) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt >= maxBatchAttempts {
+				return fmt.Errorf("batch write left %d articles unprocessed: %s", len(request[s.ArticlesTable]), unprocessedURLs(request[s.ArticlesTable]))
+			}
+			if err := sleepContext(ctx, time.Duration(50<<uint(attempt))*time.Millisecond); err != nil {
+				return err
+			}
+		}
+		out, err := s.Client.BatchWriteItem(ctx, &ddb.BatchWriteItemInput{RequestItems: request})
+		if err != nil {
+			return fmt.Errorf("failed to batch write %d articles: %w", len(request[s.ArticlesTable]), err)
+		}
+		request = out.UnprocessedItems
+	}
+	return nil
+}
+
+// unprocessedURLs lists the article URLs of pending put requests for error messages.
+func unprocessedURLs(writes []ddbTypes.WriteRequest) string {
+	urls := make([]string, 0, len(writes))
+	for _, w := range writes {
+		if w.PutRequest != nil {
+			urls = append(urls, stringAttr(w.PutRequest.Item, "url"))
+		}
+	}
+	return strings.Join(urls, ", ")
+}
+
+// articleItem builds the articles table item for one sent article.
+func articleItem(runID string, art ArticleWithContent, storedAt time.Time) map[string]ddbTypes.AttributeValue {
+	expirationTime := storedAt.AddDate(0, 6, 0).Unix() // Unix timestamp (seconds)
+	item := map[string]ddbTypes.AttributeValue{
+		"url":        &ddbTypes.AttributeValueMemberS{Value: CanonicalURL(art.URL)},
+		"Link":       &ddbTypes.AttributeValueMemberS{Value: art.URL},
+		"Title":      &ddbTypes.AttributeValueMemberS{Value: art.Title},
+		"Excerpt":    &ddbTypes.AttributeValueMemberS{Value: art.Excerpt},
+		"StoredAt":   &ddbTypes.AttributeValueMemberS{Value: storedAt.Format(time.RFC3339)},
+		"StoredDate": &ddbTypes.AttributeValueMemberS{Value: storedAt.Format("2006-01-02")},
+		"TTL":        &ddbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", expirationTime)},
+		"Score":      &ddbTypes.AttributeValueMemberN{Value: strconv.Itoa(art.Score)},
+		"Confidence": &ddbTypes.AttributeValueMemberN{Value: strconv.FormatFloat(art.Confidence, 'f', -1, 64)},
+	}
+	if art.ImageURL != "" {
+		item["ImageURL"] = &ddbTypes.AttributeValueMemberS{Value: art.ImageURL}
+	}
+	if art.Category != "" {
+		item["Category"] = &ddbTypes.AttributeValueMemberS{Value: art.Category}
+	}
+	if art.Reason != "" {
+		item["Reason"] = &ddbTypes.AttributeValueMemberS{Value: art.Reason}
+	}
+	if runID != "" {
+		item["RunID"] = &ddbTypes.AttributeValueMemberS{Value: runID}
+	}
+	if len(art.Flags) > 0 {
+		item["Flags"] = &ddbTypes.AttributeValueMemberSS{Value: art.Flags}
+	}
+	if art.Fingerprint != 0 {
+		item["Fingerprint"] = &ddbTypes.AttributeValueMemberN{Value: strconv.FormatUint(art.Fingerprint, 10)}
+	}
+	return item
+}
+
+// RecentArticles reads every article stored at or after since from StoredDateIndex,
+// one day bucket at a time, following LastEvaluatedKey until every page is read.
+func (s *DynamoStore) RecentArticles(ctx context.Context, since time.Time) ([]StoredArticle, error) {
+	sinceStamp := since.UTC().Format(time.RFC3339)
+	today := time.Now().UTC().Format("2006-01-02")
+	var articles []StoredArticle
+	for day := since.UTC(); day.Format("2006-01-02") <= today; day = day.AddDate(0, 0, 1) {
+		input := &ddb.QueryInput{
+			TableName:              aws.String(s.ArticlesTable),
+			IndexName:              aws.String(StoredDateIndex),
+			KeyConditionExpression: aws.String("StoredDate = :day AND StoredAt >= :since"),
+			ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+				":day":   &ddbTypes.AttributeValueMemberS{Value: day.Format("2006-01-02")},
+				":since": &ddbTypes.AttributeValueMemberS{Value: sinceStamp},
+			},
+		}
+		paginator := ddb.NewQueryPaginator(s.Client, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query %s for %s: %w", StoredDateIndex, day.Format("2006-01-02"), err)
+			}
+			for _, item := range page.Items {
+				articles = append(articles, storedArticleFromItem(item))
+			}
+		}
+	}
+	return articles, nil
+}
+
+// storedArticleFromItem decodes an articles table item, ignoring attributes it doesn't know.
+func storedArticleFromItem(item map[string]ddbTypes.AttributeValue) StoredArticle {
+	var art StoredArticle
+	art.URL = stringAttr(item, "Link")
+	if art.URL == "" {
+		art.URL = stringAttr(item, "url") // stored before links were kept separately
+	}
+	art.Title = stringAttr(item, "Title")
+	art.Excerpt = stringAttr(item, "Excerpt")
+	art.ImageURL = stringAttr(item, "ImageURL")
+	art.Category = stringAttr(item, "Category")
+	art.Reason = stringAttr(item, "Reason")
+	art.RunID = stringAttr(item, "RunID")
+	art.StoredAt, _ = time.Parse(time.RFC3339, stringAttr(item, "StoredAt"))
+	art.Score, _ = strconv.Atoi(numberAttr(item, "Score"))
+	art.Confidence, _ = strconv.ParseFloat(numberAttr(item, "Confidence"), 64)
+	art.Fingerprint, _ = strconv.ParseUint(numberAttr(item, "Fingerprint"), 10, 64)
+	if flags, ok := item["Flags"].(*ddbTypes.AttributeValueMemberSS); ok {
+		art.Flags = flags.Value
 	}
-	result, err := ddbClient.Scan(ctx, input)
+	return art
+}
+
+func stringAttr(item map[string]ddbTypes.AttributeValue, name string) string {
+	if v, ok := item[name].(*ddbTypes.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func numberAttr(item map[string]ddbTypes.AttributeValue, name string) string {
+	if v, ok := item[name].(*ddbTypes.AttributeValueMemberN); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// batchGetLimit is the maximum number of keys per BatchGetItem request.
+const batchGetLimit = 100
+
+// AlreadySent returns the subset of urls stored within the history window, using BatchGetItem
+// so the cost grows with the number of candidates rather than the size of the table.
+func (s *DynamoStore) AlreadySent(ctx context.Context, urls []string) (map[string]bool, error) {
+	items, err := s.batchGetArticles(ctx, urls)
 	if err != nil {
 		return nil, err
 	}
-	recent := make(map[string]bool)
-	for _, item := range result.Items {
-		if urlAttr, ok := item["url"].(*ddbTypes.AttributeValueMemberS); ok {
-			recent[urlAttr.Value] = true
+	since := historyCutoff(s.HistoryDays).Format(time.RFC3339)
+	sent := make(map[string]bool)
+	for _, item := range items {
+		if storedAt := stringAttr(item, "StoredAt"); storedAt != "" && storedAt < since {
+			continue
 		}
+		sent[stringAttr(item, "url")] = true
 	}
-	return recent, nil
+	return sent, nil
 }
 
-// storeArticles saves the selected articles to DynamoDB.
-func StoreArticles(ctx context.Context, articles []ArticleWithContent) error {
-	cfg, _ := LoadAWSConfig(ctx)
-	ddbClient := ddb.NewFromConfig(cfg)
-	expirationTime := time.Now().AddDate(0, 6, 0).Unix() // Unix timestamp (seconds)
-	for _, art := range articles {
-		item := map[string]ddbTypes.AttributeValue{
-			"url":      &ddbTypes.AttributeValueMemberS{Value: art.URL},
-			"Title":    &ddbTypes.AttributeValueMemberS{Value: art.Title},
-			"Excerpt":  &ddbTypes.AttributeValueMemberS{Value: art.Excerpt},
-			"StoredAt": &ddbTypes.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
-			"TTL":      &ddbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", expirationTime)},
-		}
-		input := &ddb.PutItemInput{
-			TableName: aws.String(TableName),
-			Item:      item,
-		}
-		_, err := ddbClient.PutItem(ctx, input)
+// batchGetArticles fetches the url and StoredAt of every stored article among urls.
+// Keys DynamoDB leaves unprocessed are retried with backoff.
+func (s *DynamoStore) batchGetArticles(ctx context.Context, urls []string) ([]map[string]ddbTypes.AttributeValue, error) {
+	unique := make(map[string]bool)
+	var keys []map[string]ddbTypes.AttributeValue
+	for _, u := range urls {
+		if unique[u] {
+			continue
+		}
+		unique[u] = true
+		keys = append(keys, map[string]ddbTypes.AttributeValue{"url": &ddbTypes.AttributeValueMemberS{Value: u}})
+	}
+	var items []map[string]ddbTypes.AttributeValue
+	for start := 0; start < len(keys); start += batchGetLimit {
+		end := start + batchGetLimit
+		if end > len(keys) {
+			end = len(keys)
+		}
+		request := map[string]ddbTypes.KeysAndAttributes{
+			s.ArticlesTable: {
+				Keys:                     keys[start:end],
+				ProjectionExpression:     aws.String("#url, StoredAt"),
+				ExpressionAttributeNames: map[string]string{"#url": "url"},
+			},
+		}
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt > 0 {
+				if attempt >= maxBatchAttempts {
+					return nil, fmt.Errorf("batch get left %d keys unprocessed", len(request[s.ArticlesTable].Keys))
+				}
+				if err := sleepContext(ctx, time.Duration(50<<uint(attempt))*time.Millisecond); err != nil {
+					return nil, err
+				}
+			}
+			out, err := s.Client.BatchGetItem(ctx, &ddb.BatchGetItemInput{RequestItems: request})
+			if err != nil {
+				return nil, fmt.Errorf("failed to batch get articles: %w", err)
+			}
+			items = append(items, out.Responses[s.ArticlesTable]...)
+			request = out.UnprocessedKeys
+		}
+	}
+	return items, nil
+}
+
+// SaveRun writes a run record to the runs table.
+func (s *DynamoStore) SaveRun(ctx context.Context, run DigestRun) error {
+	urls := make([]ddbTypes.AttributeValue, 0, len(run.ArticleURLs))
+	for _, u := range run.ArticleURLs {
+		urls = append(urls, &ddbTypes.AttributeValueMemberS{Value: u})
+	}
+	if run.FinishedAt.IsZero() {
+		run.FinishedAt = time.Now()
+	}
+	item := map[string]ddbTypes.AttributeValue{
+		"runId":       &ddbTypes.AttributeValueMemberS{Value: run.RunID},
+		"RunDate":     &ddbTypes.AttributeValueMemberS{Value: run.StartedAt.UTC().Format("2006-01-02")},
+		"StartedAt":   &ddbTypes.AttributeValueMemberS{Value: run.StartedAt.UTC().Format(time.RFC3339)},
+		"FinishedAt":  &ddbTypes.AttributeValueMemberS{Value: run.FinishedAt.UTC().Format(time.RFC3339)},
+		"ArticleURLs": &ddbTypes.AttributeValueMemberL{Value: urls},
+		"Ranker":      &ddbTypes.AttributeValueMemberS{Value: run.Ranker},
+		"SendStatus":  &ddbTypes.AttributeValueMemberS{Value: run.SendStatus},
+	}
+	if run.Error != "" {
+		item["Error"] = &ddbTypes.AttributeValueMemberS{Value: run.Error}
+	}
+	if run.Mailer != "" {
+		item["Mailer"] = &ddbTypes.AttributeValueMemberS{Value: run.Mailer}
+	}
+	if len(run.Deliveries) > 0 {
+		deliveries := make([]ddbTypes.AttributeValue, 0, len(run.Deliveries))
+		for _, d := range run.Deliveries {
+			m := map[string]ddbTypes.AttributeValue{
+				"Recipient": &ddbTypes.AttributeValueMemberS{Value: d.Recipient},
+			}
+			if d.RecipientHash != "" {
+				m["RecipientHash"] = &ddbTypes.AttributeValueMemberS{Value: d.RecipientHash}
+			}
+			if d.MessageID != "" {
+				m["MessageId"] = &ddbTypes.AttributeValueMemberS{Value: d.MessageID}
+			}
+			if d.Error != "" {
+				m["Error"] = &ddbTypes.AttributeValueMemberS{Value: d.Error}
+			}
+			deliveries = append(deliveries, &ddbTypes.AttributeValueMemberM{Value: m})
+		}
+		item["Deliveries"] = &ddbTypes.AttributeValueMemberL{Value: deliveries}
+	}
+	_, err := s.Client.PutItem(ctx, &ddb.PutItemInput{
+		TableName: aws.String(s.RunsTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store digest run %s: %w", run.RunID, err)
+	}
+	return nil
+}
+
+// RunsOn queries RunDateIndex for the runs started on the given UTC day.
+func (s *DynamoStore) RunsOn(ctx context.Context, day time.Time) ([]DigestRun, error) {
+	input := &ddb.QueryInput{
+		TableName:              aws.String(s.RunsTable),
+		IndexName:              aws.String(RunDateIndex),
+		KeyConditionExpression: aws.String("RunDate = :day"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":day": &ddbTypes.AttributeValueMemberS{Value: day.UTC().Format("2006-01-02")},
+		},
+	}
+	var runs []DigestRun
+	paginator := ddb.NewQueryPaginator(s.Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			fmt.Printf("Failed to store article '%s': %v\n", art.Title, err)
-		} else {
-			fmt.Printf("Stored article: %s\n", art.Title)
+			return nil, fmt.Errorf("failed to query %s: %w", RunDateIndex, err)
+		}
+		for _, item := range page.Items {
+			run := DigestRun{
+				RunID:      stringAttr(item, "runId"),
+				Ranker:     stringAttr(item, "Ranker"),
+				SendStatus: stringAttr(item, "SendStatus"),
+				Error:      stringAttr(item, "Error"),
+				Mailer:     stringAttr(item, "Mailer"),
+			}
+			run.StartedAt, _ = time.Parse(time.RFC3339, stringAttr(item, "StartedAt"))
+			run.FinishedAt, _ = time.Parse(time.RFC3339, stringAttr(item, "FinishedAt"))
+			if list, ok := item["ArticleURLs"].(*ddbTypes.AttributeValueMemberL); ok {
+				for _, v := range list.Value {
+					if u, ok := v.(*ddbTypes.AttributeValueMemberS); ok {
+						run.ArticleURLs = append(run.ArticleURLs, u.Value)
+					}
+				}
+			}
+			if list, ok := item["Deliveries"].(*ddbTypes.AttributeValueMemberL); ok {
+				for _, v := range list.Value {
+					if m, ok := v.(*ddbTypes.AttributeValueMemberM); ok {
+						run.Deliveries = append(run.Deliveries, Delivery{
+							Recipient:     stringAttr(m.Value, "Recipient"),
+							RecipientHash: stringAttr(m.Value, "RecipientHash"),
+							MessageID:     stringAttr(m.Value, "MessageId"),
+							Error:         stringAttr(m.Value, "Error"),
+						})
+					}
+				}
+			}
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// runLockKey is the runs table key of the lock item for a day. Lock items have no RunDate,
+// so they stay out of RunDateIndex.
+func runLockKey(date string) map[string]ddbTypes.AttributeValue {
+	return map[string]ddbTypes.AttributeValue{
+		"runId": &ddbTypes.AttributeValueMemberS{Value: "lock#" + date},
+	}
+}
+
+// AcquireRunLock reads the day's lock item and replaces it with a conditional PutItem that
+// fails if another invocation changed it in between.
+func (s *DynamoStore) AcquireRunLock(ctx context.Context, day time.Time, runID string, lease time.Duration, force bool) (RunLock, error) {
+	date := day.UTC().Format("2006-01-02")
+	out, err := s.Client.GetItem(ctx, &ddb.GetItemInput{
+		TableName:      aws.String(s.RunsTable),
+		Key:            runLockKey(date),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return RunLock{}, fmt.Errorf("failed to read run lock for %s: %w", date, err)
+	}
+
+	var current *RunLock
+	input := &ddb.PutItemInput{
+		TableName:           aws.String(s.RunsTable),
+		ConditionExpression: aws.String("attribute_not_exists(runId)"),
+	}
+	if out.Item != nil {
+		lock := runLockFromItem(out.Item)
+		current = &lock
+		input.ConditionExpression = aws.String("AcquiredAt = :acquired")
+		input.ExpressionAttributeValues = map[string]ddbTypes.AttributeValue{
+			":acquired": &ddbTypes.AttributeValueMemberS{Value: stringAttr(out.Item, "AcquiredAt")},
 		}
 	}
+	next, err := nextRunLock(current, day, runID, time.Now(), lease, force)
+	if err != nil {
+		return RunLock{}, err
+	}
+
+	input.Item = runLockKey(date)
+	input.Item["LockDate"] = &ddbTypes.AttributeValueMemberS{Value: next.Date}
+	input.Item["LockRunId"] = &ddbTypes.AttributeValueMemberS{Value: next.RunID}
+	input.Item["LockStatus"] = &ddbTypes.AttributeValueMemberS{Value: next.Status}
+	input.Item["AcquiredAt"] = &ddbTypes.AttributeValueMemberS{Value: next.AcquiredAt.Format(time.RFC3339Nano)}
+	input.Item["ExpiresAt"] = &ddbTypes.AttributeValueMemberS{Value: next.ExpiresAt.Format(time.RFC3339Nano)}
+	input.Item["TTL"] = &ddbTypes.AttributeValueMemberN{Value: strconv.FormatInt(next.AcquiredAt.AddDate(0, 1, 0).Unix(), 10)}
+
+	if _, err := s.Client.PutItem(ctx, input); err != nil {
+		var conditionFailed *ddbTypes.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return RunLock{}, &RunLockError{Date: date, Status: RunStatusRunning}
+		}
+		return RunLock{}, fmt.Errorf("failed to write run lock for %s: %w", date, err)
+	}
+	return next, nil
+}
+
+// ReleaseRunLock sets the lock's status, provided it still belongs to the same acquisition.
+func (s *DynamoStore) ReleaseRunLock(ctx context.Context, lock RunLock, status string) error {
+	_, err := s.Client.UpdateItem(ctx, &ddb.UpdateItemInput{
+		TableName:           aws.String(s.RunsTable),
+		Key:                 runLockKey(lock.Date),
+		UpdateExpression:    aws.String("SET LockStatus = :status"),
+		ConditionExpression: aws.String("LockRunId = :run AND AcquiredAt = :acquired"),
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":status":   &ddbTypes.AttributeValueMemberS{Value: status},
+			":run":      &ddbTypes.AttributeValueMemberS{Value: lock.RunID},
+			":acquired": &ddbTypes.AttributeValueMemberS{Value: lock.AcquiredAt.Format(time.RFC3339Nano)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release run lock for %s: %w", lock.Date, err)
+	}
 	return nil
 }
+
+func runLockFromItem(item map[string]ddbTypes.AttributeValue) RunLock {
+	lock := RunLock{
+		Date:   stringAttr(item, "LockDate"),
+		RunID:  stringAttr(item, "LockRunId"),
+		Status: stringAttr(item, "LockStatus"),
+	}
+	lock.AcquiredAt, _ = time.Parse(time.RFC3339Nano, stringAttr(item, "AcquiredAt"))
+	lock.ExpiresAt, _ = time.Parse(time.RFC3339Nano, stringAttr(item, "ExpiresAt"))
+	return lock
+}
+
+// GetSubscriber reads a subscriber by email. Expired pending records that TTL has not deleted yet
+// count as missing.
+func (s *DynamoStore) GetSubscriber(ctx context.Context, email string) (*Subscriber, error) {
+	out, err := s.Client.GetItem(ctx, &ddb.GetItemInput{
+		TableName: aws.String(s.SubscribersTable),
+		Key: map[string]ddbTypes.AttributeValue{
+			"email": &ddbTypes.AttributeValueMemberS{Value: email},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriber %s: %w", email, err)
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+	sub := subscriberFromItem(out.Item)
+	if sub.Expired(time.Now()) {
+		return nil, nil
+	}
+	return &sub, nil
+}
+
+// PutSubscriber writes a subscriber.
+func (s *DynamoStore) PutSubscriber(ctx context.Context, sub Subscriber) error {
+	item := map[string]ddbTypes.AttributeValue{
+		"email":     &ddbTypes.AttributeValueMemberS{Value: sub.Email},
+		"Status":    &ddbTypes.AttributeValueMemberS{Value: sub.Status},
+		"CreatedAt": &ddbTypes.AttributeValueMemberS{Value: sub.CreatedAt.UTC().Format(time.RFC3339)},
+		"UpdatedAt": &ddbTypes.AttributeValueMemberS{Value: sub.UpdatedAt.UTC().Format(time.RFC3339)},
+	}
+	if sub.Name != "" {
+		item["Name"] = &ddbTypes.AttributeValueMemberS{Value: sub.Name}
+	}
+	if !sub.ConfirmedAt.IsZero() {
+		item["ConfirmedAt"] = &ddbTypes.AttributeValueMemberS{Value: sub.ConfirmedAt.UTC().Format(time.RFC3339)}
+	}
+	if len(sub.Preferences.Categories) > 0 {
+		item["Categories"] = &ddbTypes.AttributeValueMemberSS{Value: sub.Preferences.Categories}
+	}
+	if !sub.ExpiresAt.IsZero() {
+		// DynamoDB TTL deletes unconfirmed records some time after they expire.
+		item["ExpiresAt"] = &ddbTypes.AttributeValueMemberS{Value: sub.ExpiresAt.UTC().Format(time.RFC3339)}
+		item["TTL"] = &ddbTypes.AttributeValueMemberN{Value: strconv.FormatInt(sub.ExpiresAt.Unix(), 10)}
+	}
+	if !sub.ConfirmSentAt.IsZero() {
+		item["ConfirmSentAt"] = &ddbTypes.AttributeValueMemberS{Value: sub.ConfirmSentAt.UTC().Format(time.RFC3339)}
+	}
+	_, err := s.Client.PutItem(ctx, &ddb.PutItemInput{
+		TableName: aws.String(s.SubscribersTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store subscriber %s: %w", sub.Email, err)
+	}
+	return nil
+}
+
+// SubscribersByStatus queries SubscriberStatusIndex for one status, skipping expired pending records.
+func (s *DynamoStore) SubscribersByStatus(ctx context.Context, status string) ([]Subscriber, error) {
+	input := &ddb.QueryInput{
+		TableName:              aws.String(s.SubscribersTable),
+		IndexName:              aws.String(SubscriberStatusIndex),
+		KeyConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":status": &ddbTypes.AttributeValueMemberS{Value: status},
+		},
+	}
+	var subs []Subscriber
+	paginator := ddb.NewQueryPaginator(s.Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", SubscriberStatusIndex, err)
+		}
+		for _, item := range page.Items {
+			if sub := subscriberFromItem(item); !sub.Expired(time.Now()) {
+				subs = append(subs, sub)
+			}
+		}
+	}
+	return subs, nil
+}
+
+func subscriberFromItem(item map[string]ddbTypes.AttributeValue) Subscriber {
+	sub := Subscriber{
+		Email:  stringAttr(item, "email"),
+		Name:   stringAttr(item, "Name"),
+		Status: stringAttr(item, "Status"),
+	}
+	sub.CreatedAt, _ = time.Parse(time.RFC3339, stringAttr(item, "CreatedAt"))
+	sub.ConfirmedAt, _ = time.Parse(time.RFC3339, stringAttr(item, "ConfirmedAt"))
+	sub.UpdatedAt, _ = time.Parse(time.RFC3339, stringAttr(item, "UpdatedAt"))
+	sub.ExpiresAt, _ = time.Parse(time.RFC3339, stringAttr(item, "ExpiresAt"))
+	sub.ConfirmSentAt, _ = time.Parse(time.RFC3339, stringAttr(item, "ConfirmSentAt"))
+	if ss, ok := item["Categories"].(*ddbTypes.AttributeValueMemberSS); ok {
+		sub.Preferences.Categories = ss.Value
+	}
+	return sub
+}
+
+// sleepContext sleeps for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamo serves BatchGetItem from items and leaves the last unprocessedPerCall requests of
+// every BatchWriteItem call unprocessed until retries run out of them.
+type fakeDynamo struct {
+	DynamoAPI // methods the tests don't use panic
+
+	items              map[string]map[string]ddbTypes.AttributeValue
+	unprocessedPerCall int
+	unprocessedBudget  int
+	writeCalls         []int // requests per BatchWriteItem call
+}
+
+func (f *fakeDynamo) BatchGetItem(ctx context.Context, in *ddb.BatchGetItemInput, optFns ...func(*ddb.Options)) (*ddb.BatchGetItemOutput, error) {
+	out := &ddb.BatchGetItemOutput{Responses: map[string][]map[string]ddbTypes.AttributeValue{}}
+	for table, ka := range in.RequestItems {
+		for _, key := range ka.Keys {
+			if item, ok := f.items[stringAttr(key, "url")]; ok {
+				out.Responses[table] = append(out.Responses[table], item)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeDynamo) BatchWriteItem(ctx context.Context, in *ddb.BatchWriteItemInput, optFns ...func(*ddb.Options)) (*ddb.BatchWriteItemOutput, error) {
+	out := &ddb.BatchWriteItemOutput{}
+	for table, writes := range in.RequestItems {
+		if len(writes) > batchWriteLimit {
+			return nil, fmt.Errorf("%d requests in one batch", len(writes))
+		}
+		f.writeCalls = append(f.writeCalls, len(writes))
+		keep := len(writes)
+		if n := min(f.unprocessedPerCall, f.unprocessedBudget, len(writes)); n > 0 {
+			keep -= n
+			f.unprocessedBudget -= n
+			out.UnprocessedItems = map[string][]ddbTypes.WriteRequest{table: writes[keep:]}
+		}
+		for _, w := range writes[:keep] {
+			f.items[stringAttr(w.PutRequest.Item, "url")] = w.PutRequest.Item
+		}
+	}
+	return out, nil
+}
+
+func TestDynamoStoreArticlesBatchesAndRetries(t *testing.T) {
+	now := time.Now().UTC()
+	fresh := map[string]ddbTypes.AttributeValue{
+		"url":      &ddbTypes.AttributeValueMemberS{Value: "https://example.com/fresh"},
+		"Title":    &ddbTypes.AttributeValueMemberS{Value: "kept"},
+		"StoredAt": &ddbTypes.AttributeValueMemberS{Value: now.AddDate(0, 0, -1).Format(time.RFC3339)},
+	}
+	stale := map[string]ddbTypes.AttributeValue{
+		"url":      &ddbTypes.AttributeValueMemberS{Value: "https://example.com/stale"},
+		"Title":    &ddbTypes.AttributeValueMemberS{Value: "replaced"},
+		"StoredAt": &ddbTypes.AttributeValueMemberS{Value: now.AddDate(0, 0, -60).Format(time.RFC3339)},
+	}
+	client := &fakeDynamo{
+		items:              map[string]map[string]ddbTypes.AttributeValue{"https://example.com/fresh": fresh, "https://example.com/stale": stale},
+		unprocessedPerCall: 3,
+		unprocessedBudget:  4,
+	}
+	store := &DynamoStore{Client: client, ArticlesTable: "articles", HistoryDays: 30}
+
+	articles := []ArticleWithContent{
+		{URL: "https://example.com/fresh", Title: "new title"},
+		{URL: "https://example.com/stale", Title: "new title"},
+	}
+	for i := 0; i < 28; i++ {
+		articles = append(articles, ArticleWithContent{URL: fmt.Sprintf("https://example.com/%d", i), Title: "story"})
+	}
+	articles = append(articles, articles[2]) // the same story twice in one batch
+
+	if err := store.StoreArticles(context.Background(), "run-1", articles); err != nil {
+		t.Fatalf("StoreArticles: %v", err)
+	}
+	if got := len(client.items); got != 30 {
+		t.Errorf("table has %d items, want 30", got)
+	}
+	if title := stringAttr(client.items["https://example.com/fresh"], "Title"); title != "kept" {
+		t.Errorf("fresh entry overwritten: title %q", title)
+	}
+	if title := stringAttr(client.items["https://example.com/stale"], "Title"); title != "new title" {
+		t.Errorf("stale entry not refreshed: title %q", title)
+	}
+	// 29 writes: 25 (3 left unprocessed, then 1 of those again) and 4.
+	want := []int{25, 3, 1, 4}
+	if fmt.Sprint(client.writeCalls) != fmt.Sprint(want) {
+		t.Errorf("BatchWriteItem calls = %v, want %v", client.writeCalls, want)
+	}
+}
+
+func TestDynamoStoreArticlesGivesUpOnUnprocessed(t *testing.T) {
+	client := &fakeDynamo{
+		items:              map[string]map[string]ddbTypes.AttributeValue{},
+		unprocessedPerCall: 1,
+		unprocessedBudget:  maxBatchAttempts,
+	}
+	store := &DynamoStore{Client: client, ArticlesTable: "articles", HistoryDays: 30}
+	articles := []ArticleWithContent{{URL: "https://example.com/stuck"}}
+	err := store.StoreArticles(context.Background(), "run-1", articles)
+	if err == nil || len(client.items) != 0 {
+		t.Fatalf("StoreArticles = %v with %d items, want an unprocessed error", err, len(client.items))
+	}
+	if len(client.writeCalls) != maxBatchAttempts {
+		t.Errorf("%d BatchWriteItem calls, want %d", len(client.writeCalls), maxBatchAttempts)
+	}
+}
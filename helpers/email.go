@@ -2,22 +2,144 @@
 package helpers
 
 import (
+	"bytes"
+	_ "embed"
 	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	texttemplate "text/template"
+	"time"
 )
 
-// BuildPlainMessage generates the email content with the top articles and a pre-signed S3 URL
-// BuildPlainMessage generates the email content with a top 10 news list and a link to the website
-func BuildPlainMessage(topArticles []ArticleWithContent, preSignedURL string) string {
-	plainMessage := "Hello,\n\n"
-	plainMessage += "Here are your top 10 positively ranked articles for today:\n\n"
+// DigestSubject is the subject line of the daily email.
+const DigestSubject = "Your Daily Uplifting News"
 
-	plainMessage += "Check out the latest positive news articles on our website 🌟: http://bit.ly/3CNTB7C\n\n"
+// ConfirmationSubject is the subject line of the double opt-in email.
+const ConfirmationSubject = "Please confirm your subscription"
 
-	for i, art := range topArticles {
-		plainMessage += fmt.Sprintf("%d. %s\n%s\n\n", i+1, art.Title, art.URL)
+// UpdateSubject is the subject line of the email confirming changes to an active subscription.
+const UpdateSubject = "Please confirm the changes to your subscription"
+
+//go:embed templates/digest.html.tmpl
+var defaultHTMLTemplate string
+
+//go:embed templates/digest.txt.tmpl
+var defaultTextTemplate string
+
+//go:embed templates/confirm.html.tmpl
+var confirmHTMLTemplate string
+
+//go:embed templates/confirm.txt.tmpl
+var confirmTextTemplate string
+
+// Digest is the data the email templates are rendered with.
+type Digest struct {
+	Subject        string
+	Name           string // recipient's name, if known
+	Articles       []ArticleWithContent
+	WebsiteURL     string
+	UnsubscribeURL string // per-recipient link, if the delivery channel supports one
+}
+
+// Confirmation is the data the confirmation email templates are rendered with.
+type Confirmation struct {
+	Subject    string
+	Name       string
+	Email      string
+	ConfirmURL string
+	Expires    time.Time
+	Update     bool // confirming changes to an active subscription rather than a new one
+}
+
+// RenderedEmail is a digest rendered as an HTML body with a plain-text alternative.
+type RenderedEmail struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// EmailRenderer renders digests with an HTML and a plain-text template.
+type EmailRenderer struct {
+	HTML *htmltemplate.Template
+	Text *texttemplate.Template
+}
+
+// templateFuncs are available to both templates.
+var templateFuncs = map[string]interface{}{
+	"inc": func(i int) int { return i + 1 },
+}
+
+// NewEmailRenderer parses the templates at htmlPath and textPath. An empty path selects the
+// built-in template from helpers/templates.
+func NewEmailRenderer(htmlPath, textPath string) (*EmailRenderer, error) {
+	htmlSrc, err := templateSource(htmlPath, defaultHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+	textSrc, err := templateSource(textPath, defaultTextTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return parseEmailTemplates("digest", htmlSrc, textSrc)
+}
+
+func parseEmailTemplates(name, htmlSrc, textSrc string) (*EmailRenderer, error) {
+	htmlTmpl, err := htmltemplate.New(name + ".html").Funcs(templateFuncs).Parse(htmlSrc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML email template: %w", err)
+	}
+	textTmpl, err := texttemplate.New(name + ".txt").Funcs(templateFuncs).Parse(textSrc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text email template: %w", err)
 	}
+	return &EmailRenderer{HTML: htmlTmpl, Text: textTmpl}, nil
+}
 
-	plainMessage += "\nHave a wonderful day!\n"
+func templateSource(path, builtin string) (string, error) {
+	if path == "" {
+		return builtin, nil
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read email template: %w", err)
+	}
+	return string(raw), nil
+}
+
+// Render executes both templates for the digest.
+func (r *EmailRenderer) Render(d Digest) (RenderedEmail, error) {
+	return r.execute(d.Subject, d)
+}
 
-	return plainMessage
+func (r *EmailRenderer) execute(subject string, data interface{}) (RenderedEmail, error) {
+	var html, text bytes.Buffer
+	if err := r.HTML.Execute(&html, data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render HTML email: %w", err)
+	}
+	if err := r.Text.Execute(&text, data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render text email: %w", err)
+	}
+	return RenderedEmail{Subject: subject, HTML: html.String(), Text: text.String()}, nil
+}
+
+// RenderDigest renders the articles with the configured templates and website link.
+func RenderDigest(cfg Config, articles []ArticleWithContent) (RenderedEmail, error) {
+	renderer, err := NewEmailRenderer(cfg.EmailHTMLTemplate, cfg.EmailTextTemplate)
+	if err != nil {
+		return RenderedEmail{}, err
+	}
+	return renderer.Render(Digest{
+		Subject:    DigestSubject,
+		Articles:   articles,
+		WebsiteURL: cfg.Website(),
+	})
+}
+
+// RenderConfirmation renders the double opt-in email with the built-in confirmation templates.
+func RenderConfirmation(c Confirmation) (RenderedEmail, error) {
+	renderer, err := parseEmailTemplates("confirm", confirmHTMLTemplate, confirmTextTemplate)
+	if err != nil {
+		return RenderedEmail{}, err
+	}
+	return renderer.execute(c.Subject, c)
 }
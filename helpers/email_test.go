@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var goldenArticles = []ArticleWithContent{
+	{
+		Title:    "Volunteers plant a million trees",
+		URL:      "https://example.com/trees",
+		Excerpt:  "A community effort to restore the forest & bring back wildlife.",
+		ImageURL: "https://example.com/trees.jpg",
+		Category: "science",
+	},
+	{
+		Title:    "Local café feeds neighbours for free",
+		URL:      "https://example.com/cafe?id=1&ref=home",
+		Excerpt:  "Every Sunday the kitchen opens its doors.",
+		Category: "lifestyle",
+	},
+	{
+		Title: "Rescued puppy finds a <home>",
+		URL:   "https://example.com/puppy",
+	},
+}
+
+// goldenDigests are the fixed inputs rendered into testdata/digest.*.golden.
+var goldenDigests = []struct {
+	name   string
+	digest Digest
+}{
+	{"no articles", Digest{Subject: DigestSubject, WebsiteURL: "https://news.example.com/"}},
+	{"one article", Digest{Subject: DigestSubject, Articles: goldenArticles[:1], WebsiteURL: "https://news.example.com/"}},
+	{"several articles", Digest{Subject: DigestSubject, Articles: goldenArticles, WebsiteURL: "https://news.example.com/"}},
+	{"named subscriber with unsubscribe link", Digest{
+		Subject:        DigestSubject,
+		Name:           "Sam",
+		Articles:       goldenArticles,
+		WebsiteURL:     "https://news.example.com/",
+		UnsubscribeURL: "https://news.example.com/unsubscribe?token=abc",
+	}},
+}
+
+func TestDigestTemplatesGolden(t *testing.T) {
+	renderer, err := NewEmailRenderer("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var html, text bytes.Buffer
+	for _, tt := range goldenDigests {
+		email, err := renderer.Render(tt.digest)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		fmt.Fprintf(&html, "=== %s ===\n%s\n", tt.name, email.HTML)
+		fmt.Fprintf(&text, "=== %s ===\n%s\n", tt.name, email.Text)
+	}
+	checkGolden(t, "digest.html.golden", html.Bytes())
+	checkGolden(t, "digest.txt.golden", text.Bytes())
+}
+
+// checkGolden compares got with testdata/name, or rewrites the file when -update is set.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test ./helpers -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the rendered output; run go test ./helpers -update and review the diff", path)
+	}
+}
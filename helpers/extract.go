@@ -0,0 +1,123 @@
+// extract.go
+package helpers
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExtractOptions bounds concurrent article content downloads.
+type ExtractOptions struct {
+	Concurrency int           // maximum number of downloads in flight
+	Timeout     time.Duration // per-request timeout
+	Client      *HTTPClient   // nil uses DefaultHTTPClient
+}
+
+// DefaultExtractOptions fill in any zero fields of the ExtractOptions passed to FetchArticleContents.
+var DefaultExtractOptions = ExtractOptions{
+	Concurrency: 8,
+	Timeout:     15 * time.Second,
+}
+
+// ContentResult holds the extracted text and canonical URL for one URL, or the error that prevented it.
+type ContentResult struct {
+	URL          string
+	Content      string
+	CanonicalURL string
+	Err          error
+}
+
+// FetchArticleContents downloads the given URLs with a bounded worker pool.
+// At most one request per host is in flight at a time, and results are returned in input order.
+func FetchArticleContents(ctx context.Context, urls []string, opts ExtractOptions) []ContentResult {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultExtractOptions.Concurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultExtractOptions.Timeout
+	}
+	if opts.Client == nil {
+		opts.Client = DefaultHTTPClient
+	}
+	results := make([]ContentResult, len(urls))
+	for i, u := range urls {
+		results[i].URL = u
+	}
+	hosts := newHostLimiter()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency && w < len(urls); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].Content, results[i].CanonicalURL, results[i].Err = fetchWithHostLimit(ctx, opts.Client, hosts, urls[i], opts.Timeout)
+			}
+		}()
+	}
+	next := 0
+feed:
+	for ; next < len(urls); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	for ; next < len(urls); next++ {
+		results[next].Err = ctx.Err()
+	}
+	return results
+}
+
+// fetchWithHostLimit waits for the URL's host to be free, then fetches it with a timeout.
+func fetchWithHostLimit(ctx context.Context, client *HTTPClient, hosts *hostLimiter, articleURL string, timeout time.Duration) (string, string, error) {
+	release, err := hosts.acquire(ctx, hostOf(articleURL))
+	if err != nil {
+		return "", "", err
+	}
+	defer release()
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return FetchArticlePage(reqCtx, client, articleURL)
+}
+
+// hostLimiter allows a single in-flight request per host.
+type hostLimiter struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter() *hostLimiter {
+	return &hostLimiter{slots: make(map[string]chan struct{})}
+}
+
+func (h *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	h.mu.Lock()
+	slot, ok := h.slots[host]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		h.slots[host] = slot
+	}
+	h.mu.Unlock()
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// hostOf returns the lower-cased host of a URL, or the raw string if it cannot be parsed.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(u.Host)
+}
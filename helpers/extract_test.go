@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchArticleContentsOrderAndHostLimit(t *testing.T) {
+	var mu sync.Mutex
+	inFlight := make(map[string]int)
+	maxPerHost := make(map[string]int)
+	total, maxTotal := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight[r.Host]++
+		total++
+		maxPerHost[r.Host] = max(maxPerHost[r.Host], inFlight[r.Host])
+		maxTotal = max(maxTotal, total)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight[r.Host]--
+			total--
+			mu.Unlock()
+		}()
+		var n int
+		fmt.Sscanf(r.URL.Path, "/story/%d", &n)
+		// Later stories answer sooner, so completion order differs from input order.
+		time.Sleep(time.Duration(12-n) * 3 * time.Millisecond)
+		fmt.Fprint(w, articlePage("Story", "", fmt.Sprintf("This is story number %d and it has plenty of words in it to keep.", n)))
+	}))
+	defer srv.Close()
+
+	// The same server under two host names, so requests to one host queue while the other proceeds.
+	hosts := []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)}
+	var urls []string
+	for i := 0; i < 12; i++ {
+		urls = append(urls, fmt.Sprintf("%s/story/%d", hosts[i%2], i))
+	}
+	results := FetchArticleContents(context.Background(), urls, ExtractOptions{Concurrency: 4})
+
+	if len(results) != len(urls) {
+		t.Fatalf("got %d results for %d URLs", len(results), len(urls))
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Errorf("result %d: %v", i, res.Err)
+			continue
+		}
+		if res.URL != urls[i] || !strings.Contains(res.Content, fmt.Sprintf("story number %d ", i)) {
+			t.Errorf("result %d is for %s with content %.40q, want %s", i, res.URL, res.Content, urls[i])
+		}
+	}
+	for host, n := range maxPerHost {
+		if n > 1 {
+			t.Errorf("%d requests to %s were in flight at once, want at most 1", n, host)
+		}
+	}
+	if len(maxPerHost) != 2 || maxTotal != 2 {
+		t.Errorf("hosts %v with at most %d requests in flight, want both hosts fetched in parallel", maxPerHost, maxTotal)
+	}
+}
@@ -0,0 +1,324 @@
+// feeds.go
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// DefaultFeedURLs are the RSS/Atom feeds read when no feed list is configured.
+var DefaultFeedURLs = []string{
+	"https://www.goodnewsnetwork.org/feed/",
+	"https://www.positive.news/feed/",
+	"https://reasonstobecheerful.world/feed/",
+}
+
+// ParseFeedURLs splits a comma-separated list of feed URLs, dropping blanks.
+// An empty input yields DefaultFeedURLs.
+func ParseFeedURLs(list string) []string {
+	var urls []string
+	for _, u := range strings.Split(list, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		return DefaultFeedURLs
+	}
+	return urls
+}
+
+// FeedSource reads articles from a fixed list of RSS 2.0 or Atom feeds.
+// Feeds are not paginated, so every entry is returned on page 1 and later pages are empty.
+type FeedSource struct {
+	URLs   []string
+	Client *HTTPClient
+}
+
+// NewFeedSource returns a FeedSource for the configured feed URLs.
+func NewFeedSource(cfg Config) *FeedSource {
+	return &FeedSource{URLs: cfg.FeedURLs, Client: cfg.HTTPClient()}
+}
+
+// Name identifies the source in logs.
+func (s *FeedSource) Name() string {
+	return "feeds"
+}
+
+// FetchPage downloads every feed and returns the entries published between from and to.
+// Entries without a parseable date are kept. A feed that fails to load is logged and skipped.
+func (s *FeedSource) FetchPage(ctx context.Context, from, to time.Time, page int) ([]Article, error) {
+	if page > 1 {
+		return nil, nil
+	}
+	var articles []Article
+	var lastErr error
+	failures := 0
+	for _, feedURL := range s.URLs {
+		entries, err := s.fetchFeed(ctx, feedURL)
+		if err != nil {
+			fmt.Printf("Error reading feed %s: %v\n", feedURL, err)
+			lastErr = err
+			failures++
+			continue
+		}
+		for _, e := range entries {
+			if !e.published.IsZero() && (e.published.Before(from) || e.published.After(to)) {
+				continue
+			}
+			articles = append(articles, e.Article)
+		}
+	}
+	if len(s.URLs) > 0 && failures == len(s.URLs) {
+		return nil, fmt.Errorf("all %d feeds failed, last error: %w", failures, lastErr)
+	}
+	return articles, nil
+}
+
+// maxFeedSize caps how much of a feed is read; larger feeds are rejected rather than parsed truncated.
+const maxFeedSize = 10 << 20
+
+// fetchFeed downloads and parses a single feed.
+func (s *FeedSource) fetchFeed(ctx context.Context, feedURL string) ([]feedEntry, error) {
+	client := s.Client
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	resp, err := client.Get(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxFeedSize {
+		return nil, fmt.Errorf("feed %s is larger than %d bytes", feedURL, maxFeedSize)
+	}
+	return parseFeed(body)
+}
+
+// feedEntry is an Article together with its publication time.
+type feedEntry struct {
+	Article
+	published time.Time
+}
+
+const mediaNS = "http://search.yahoo.com/mrss/"
+
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	GUID        string         `xml:"guid"`
+	Description string         `xml:"description"`
+	PubDate     string         `xml:"pubDate"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+	Media       []mediaRef     `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails  []mediaRef     `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type rssEnclosure struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type mediaRef struct {
+	URL    string `xml:"url,attr"`
+	Medium string `xml:"medium,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title      string     `xml:"title"`
+	Links      []atomLink `xml:"link"`
+	Summary    string     `xml:"summary"`
+	Content    string     `xml:"content"`
+	Published  string     `xml:"published"`
+	Updated    string     `xml:"updated"`
+	Media      []mediaRef `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []mediaRef `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// parseFeed decodes an RSS 2.0 or Atom document into articles with their publication times.
+func parseFeed(data []byte) ([]feedEntry, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := newFeedDecoder(data).Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+	switch root.XMLName.Local {
+	case "rss":
+		var feed rssFeed
+		if err := newFeedDecoder(data).Decode(&feed); err != nil {
+			return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+		}
+		entries := make([]feedEntry, 0, len(feed.Channel.Items))
+		for _, item := range feed.Channel.Items {
+			link := strings.TrimSpace(item.Link)
+			if link == "" {
+				link = strings.TrimSpace(item.GUID)
+			}
+			if link == "" {
+				continue
+			}
+			entries = append(entries, feedEntry{
+				Article: Article{
+					Title:       cleanFeedText(item.Title),
+					Description: cleanFeedText(item.Description),
+					URL:         link,
+					ImageURL:    rssImage(item),
+				},
+				published: parseFeedTime(item.PubDate),
+			})
+		}
+		return entries, nil
+	case "feed":
+		var feed atomFeed
+		if err := newFeedDecoder(data).Decode(&feed); err != nil {
+			return nil, fmt.Errorf("failed to parse Atom feed: %w", err)
+		}
+		entries := make([]feedEntry, 0, len(feed.Entries))
+		for _, entry := range feed.Entries {
+			link := atomAlternateLink(entry.Links)
+			if link == "" {
+				continue
+			}
+			description := entry.Summary
+			if strings.TrimSpace(description) == "" {
+				description = entry.Content
+			}
+			published := parseFeedTime(entry.Published)
+			if published.IsZero() {
+				published = parseFeedTime(entry.Updated)
+			}
+			entries = append(entries, feedEntry{
+				Article: Article{
+					Title:       cleanFeedText(entry.Title),
+					Description: cleanFeedText(description),
+					URL:         link,
+					ImageURL:    atomImage(entry),
+				},
+				published: published,
+			})
+		}
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("unsupported feed root element <%s>", root.XMLName.Local)
+	}
+}
+
+func newFeedDecoder(data []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	return dec
+}
+
+// rssImage picks the first image from enclosures, media:content or media:thumbnail.
+func rssImage(item rssItem) string {
+	for _, enc := range item.Enclosures {
+		if enc.URL != "" && strings.HasPrefix(enc.Type, "image/") {
+			return enc.URL
+		}
+	}
+	if img := mediaImage(item.Media); img != "" {
+		return img
+	}
+	return mediaImage(item.Thumbnails)
+}
+
+// atomImage picks the first image from enclosure links, media:content or media:thumbnail.
+func atomImage(entry atomEntry) string {
+	for _, l := range entry.Links {
+		if l.Rel == "enclosure" && l.Href != "" && strings.HasPrefix(l.Type, "image/") {
+			return l.Href
+		}
+	}
+	if img := mediaImage(entry.Media); img != "" {
+		return img
+	}
+	return mediaImage(entry.Thumbnails)
+}
+
+func mediaImage(refs []mediaRef) string {
+	for _, m := range refs {
+		if m.URL == "" {
+			continue
+		}
+		if m.Medium == "image" || strings.HasPrefix(m.Type, "image/") || (m.Medium == "" && m.Type == "") {
+			return m.URL
+		}
+	}
+	return ""
+}
+
+// atomAlternateLink returns the entry's alternate (or first untyped) link.
+func atomAlternateLink(links []atomLink) string {
+	for _, l := range links {
+		if (l.Rel == "" || l.Rel == "alternate") && l.Href != "" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02",
+}
+
+// parseFeedTime parses the date formats commonly found in feeds, returning the zero time if none match.
+func parseFeedTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// cleanFeedText strips markup and collapses whitespace in feed titles and descriptions. Entities
+// are decoded before tags are stripped, so escaped markup cannot come out as live HTML.
+func cleanFeedText(s string) string {
+	s = html.UnescapeString(s)
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFixtureFeedServer serves the feeds in testdata/feeds and 404s for anything else.
+func newFixtureFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata/feeds")))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testFeedSource(urls ...string) *FeedSource {
+	client := NewHTTPClient("test")
+	client.MaxRetries = 0
+	return &FeedSource{URLs: urls, Client: client}
+}
+
+func TestFeedSourceFetchPage(t *testing.T) {
+	srv := newFixtureFeedServer(t)
+	src := testFeedSource(srv.URL+"/rss.xml", srv.URL+"/atom.xml", srv.URL+"/missing.xml")
+	from := time.Date(2025, 1, 24, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	got, err := src.FetchPage(context.Background(), from, to, 1)
+	if err != nil {
+		t.Fatalf("FetchPage: %v", err)
+	}
+	want := []Article{
+		{
+			Title:       "Volunteers plant a million trees",
+			Description: "A community effort to restore the forest & bring back wildlife.",
+			URL:         "https://example.com/trees",
+			ImageURL:    "https://example.com/trees.jpg",
+		},
+		{
+			Title:       "Rescued puppy finds a home",
+			Description: "Doubly escaped markup is dropped too.",
+			URL:         "https://example.com/puppy",
+			ImageURL:    "https://example.com/puppy.jpg",
+		},
+		{
+			Title:       "Town turns car park into a garden",
+			Description: "Neighbours planted 300 flowers.",
+			URL:         "https://example.org/garden",
+			ImageURL:    "https://example.org/garden.png",
+		},
+		{
+			Title:       "Undated story is kept",
+			Description: "Content is used when there is no summary.",
+			URL:         "https://example.org/undated",
+			ImageURL:    "https://example.org/undated.jpg",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchPage returned\n%+v\nwant\n%+v", got, want)
+	}
+
+	if more, err := src.FetchPage(context.Background(), from, to, 2); err != nil || len(more) != 0 {
+		t.Errorf("page 2 = %v, %v; want no articles", more, err)
+	}
+}
+
+func TestFeedSourceAllFeedsFail(t *testing.T) {
+	srv := newFixtureFeedServer(t)
+	src := testFeedSource(srv.URL+"/missing.xml", srv.URL+"/gone.xml")
+	if _, err := src.FetchPage(context.Background(), time.Time{}, time.Now(), 1); err == nil {
+		t.Error("FetchPage succeeded with no readable feeds")
+	}
+}
+
+func TestFeedSourceRejectsOversizedFeed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<?xml version="1.0"?><rss><channel><item><title>`)
+		io.Copy(w, io.LimitReader(zeros{}, maxFeedSize))
+	}))
+	defer srv.Close()
+	_, err := testFeedSource(srv.URL).fetchFeed(context.Background(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("fetchFeed of an oversized feed = %v, want a size error", err)
+	}
+}
+
+// zeros is an endless stream of '0' bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '0'
+	}
+	return len(p), nil
+}
+
+func TestCleanFeedText(t *testing.T) {
+	tests := map[string]string{
+		"&lt;img src=x onerror=alert(1)&gt;": "",
+		"<p>Hello &amp; welcome</p>":         "Hello & welcome",
+		"  spread \n out  ":                  "spread out",
+		"Fish &amp;amp; chips":               "Fish &amp; chips",
+		"1 &lt; 2":                           "1 < 2",
+	}
+	for in, want := range tests {
+		if got := cleanFeedText(in); got != want {
+			t.Errorf("cleanFeedText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// filestore.go
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultFileStorePath is where the file backend keeps its data when no path is configured.
+const DefaultFileStorePath = "positive-news-store.json"
+
+// FileStore is an ArticleStore and SubscriberStore kept in a single JSON file, for local runs and tests.
+// Every write rewrites the file atomically; it is not meant for concurrent processes.
+type FileStore struct {
+	path        string
+	historyDays int
+	mu          sync.Mutex
+	data        fileStoreData
+}
+
+type fileStoreData struct {
+	Articles    map[string]StoredArticle `json:"articles"`
+	Runs        map[string]DigestRun     `json:"runs"`
+	Locks       map[string]RunLock       `json:"locks"`
+	Subscribers map[string]Subscriber    `json:"subscribers"`
+}
+
+// OpenFileStore loads the store at path, creating an empty one if the file does not exist.
+// Articles stored within the last historyDays count as already sent.
+func OpenFileStore(path string, historyDays int) (*FileStore, error) {
+	if path == "" {
+		path = DefaultFileStorePath
+	}
+	s := &FileStore{path: path, historyDays: historyDays}
+	raw, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+	default:
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, fmt.Errorf("failed to parse store %s: %w", path, err)
+		}
+	}
+	if s.data.Articles == nil {
+		s.data.Articles = make(map[string]StoredArticle)
+	}
+	if s.data.Runs == nil {
+		s.data.Runs = make(map[string]DigestRun)
+	}
+	if s.data.Locks == nil {
+		s.data.Locks = make(map[string]RunLock)
+	}
+	if s.data.Subscribers == nil {
+		s.data.Subscribers = make(map[string]Subscriber)
+	}
+	return s, nil
+}
+
+// StoreArticles saves the articles keyed by canonical URL. Like DynamoStore, it replaces an entry
+// only if it was stored before the history window, and leaves newer entries untouched.
+func (s *FileStore) StoreArticles(ctx context.Context, runID string, articles []ArticleWithContent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	storedAt := time.Now().UTC()
+	cutoff := historyCutoff(s.historyDays)
+	for _, art := range articles {
+		key := CanonicalURL(art.URL)
+		if existing, ok := s.data.Articles[key]; ok && !existing.StoredAt.Before(cutoff) {
+			continue
+		}
+		s.data.Articles[key] = StoredArticle{ArticleWithContent: art, StoredAt: storedAt, RunID: runID}
+	}
+	return s.save()
+}
+
+// AlreadySent returns the subset of urls stored within the history window.
+func (s *FileStore) AlreadySent(ctx context.Context, urls []string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := historyCutoff(s.historyDays)
+	sent := make(map[string]bool)
+	for _, u := range urls {
+		if art, ok := s.data.Articles[u]; ok && !art.StoredAt.Before(cutoff) {
+			sent[u] = true
+		}
+	}
+	return sent, nil
+}
+
+// RecentArticles returns the articles stored at or after since, oldest first.
+func (s *FileStore) RecentArticles(ctx context.Context, since time.Time) ([]StoredArticle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var articles []StoredArticle
+	for _, art := range s.data.Articles {
+		if !art.StoredAt.Before(since) {
+			articles = append(articles, art)
+		}
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		if !articles[i].StoredAt.Equal(articles[j].StoredAt) {
+			return articles[i].StoredAt.Before(articles[j].StoredAt)
+		}
+		return articles[i].URL < articles[j].URL
+	})
+	return articles, nil
+}
+
+// SaveRun writes or replaces a run record.
+func (s *FileStore) SaveRun(ctx context.Context, run DigestRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run.FinishedAt.IsZero() {
+		run.FinishedAt = time.Now()
+	}
+	s.data.Runs[run.RunID] = run
+	return s.save()
+}
+
+// RunsOn returns the runs started on the given UTC day, oldest first.
+func (s *FileStore) RunsOn(ctx context.Context, day time.Time) ([]DigestRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	date := day.UTC().Format("2006-01-02")
+	var runs []DigestRun
+	for _, run := range s.data.Runs {
+		if run.StartedAt.UTC().Format("2006-01-02") == date {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+	return runs, nil
+}
+
+// AcquireRunLock takes the day's lock, keyed by date.
+func (s *FileStore) AcquireRunLock(ctx context.Context, day time.Time, runID string, lease time.Duration, force bool) (RunLock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current *RunLock
+	if lock, ok := s.data.Locks[day.UTC().Format("2006-01-02")]; ok {
+		current = &lock
+	}
+	next, err := nextRunLock(current, day, runID, time.Now(), lease, force)
+	if err != nil {
+		return RunLock{}, err
+	}
+	s.data.Locks[next.Date] = next
+	return next, s.save()
+}
+
+// ReleaseRunLock sets the lock's status if it still belongs to the same acquisition.
+func (s *FileStore) ReleaseRunLock(ctx context.Context, lock RunLock, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.data.Locks[lock.Date]
+	if !ok || current.RunID != lock.RunID || !current.AcquiredAt.Equal(lock.AcquiredAt) {
+		return fmt.Errorf("run lock for %s is no longer held by run %s", lock.Date, lock.RunID)
+	}
+	current.Status = status
+	s.data.Locks[lock.Date] = current
+	return s.save()
+}
+
+// GetSubscriber returns a copy of the subscriber with the email, or nil. Expired pending records
+// count as missing.
+func (s *FileStore) GetSubscriber(ctx context.Context, email string) (*Subscriber, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.data.Subscribers[email]
+	if !ok || sub.Expired(time.Now()) {
+		return nil, nil
+	}
+	return &sub, nil
+}
+
+// PutSubscriber writes or replaces a subscriber and drops expired pending records.
+func (s *FileStore) PutSubscriber(ctx context.Context, sub Subscriber) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for email, existing := range s.data.Subscribers {
+		if existing.Expired(now) {
+			delete(s.data.Subscribers, email)
+		}
+	}
+	s.data.Subscribers[sub.Email] = sub
+	return s.save()
+}
+
+// SubscribersByStatus returns the subscribers with the status, oldest first, skipping expired
+// pending records.
+func (s *FileStore) SubscribersByStatus(ctx context.Context, status string) ([]Subscriber, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var subs []Subscriber
+	for _, sub := range s.data.Subscribers {
+		if sub.Status == status && !sub.Expired(now) {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if !subs[i].CreatedAt.Equal(subs[j].CreatedAt) {
+			return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+		}
+		return subs[i].Email < subs[j].Email
+	})
+	return subs, nil
+}
+
+// save writes the store to a temporary file and renames it over the original.
+func (s *FileStore) save() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+	if dir := filepath.Dir(s.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create store directory: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write store %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace store %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreRefreshesArticlesOutsideHistory(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const url = "https://example.com/story"
+	old := time.Now().UTC().AddDate(0, 0, -45)
+	store.data.Articles[url] = StoredArticle{ArticleWithContent: ArticleWithContent{URL: url, Title: "Old"}, StoredAt: old, RunID: "old-run"}
+
+	sent, err := store.AlreadySent(ctx, []string{url})
+	if err != nil || sent[url] {
+		t.Fatalf("AlreadySent before resend = %v, %v; want not sent", sent, err)
+	}
+	if err := store.StoreArticles(ctx, "new-run", []ArticleWithContent{{URL: url, Title: "New"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.data.Articles[url]; got.RunID != "new-run" || !got.StoredAt.After(old) {
+		t.Errorf("stale entry was not refreshed: %+v", got)
+	}
+	if sent, _ := store.AlreadySent(ctx, []string{url}); !sent[url] {
+		t.Error("AlreadySent after resend = false, want true")
+	}
+
+	// An entry inside the window is left alone.
+	if err := store.StoreArticles(ctx, "third-run", []ArticleWithContent{{URL: url, Title: "Again"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.data.Articles[url]; got.RunID != "new-run" {
+		t.Errorf("recent entry was overwritten by %s", got.RunID)
+	}
+}
+
+// countingStore counts RecentArticles queries.
+type countingStore struct {
+	ArticleStore
+	queries int
+}
+
+func (s *countingStore) RecentArticles(ctx context.Context, since time.Time) ([]StoredArticle, error) {
+	s.queries++
+	return s.ArticleStore.RecentArticles(ctx, since)
+}
+
+func TestLatestDigestReadsArticlesOnce(t *testing.T) {
+	ctx := context.Background()
+	store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for url, stored := range map[string]time.Time{
+		"https://example.com/latest": now.AddDate(0, 0, -3),
+		"https://example.com/older":  now.AddDate(0, 0, -5),
+	} {
+		store.data.Articles[url] = StoredArticle{ArticleWithContent: ArticleWithContent{URL: url}, StoredAt: stored}
+	}
+
+	counting := &countingStore{ArticleStore: store}
+	day, articles, err := LatestDigest(ctx, counting, now, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.AddDate(0, 0, -3); !day.Equal(want) || len(articles) != 1 || articles[0].URL != "https://example.com/latest" {
+		t.Errorf("LatestDigest = %v %v, want %v with only the latest article", day, articles, want)
+	}
+	if counting.queries != 1 {
+		t.Errorf("RecentArticles was queried %d times, want 1", counting.queries)
+	}
+}
@@ -0,0 +1,171 @@
+// httpclient.go
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent is sent with every outbound request unless overridden.
+const DefaultUserAgent = "Mozilla/5.0 (compatible; PositiveNewsBot/1.0; +http://pk-positive-news.s3-website.us-east-2.amazonaws.com/)"
+
+// HTTPClient wraps http.Client with context propagation, status checks and retries.
+// Requests answered with 429 or 5xx are retried with jittered exponential backoff, honoring Retry-After.
+type HTTPClient struct {
+	Client     *http.Client
+	UserAgent  string
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultHTTPClient is shared by all fetchers that are not given their own client.
+var DefaultHTTPClient = NewHTTPClient(DefaultUserAgent)
+
+// NewHTTPClient returns an HTTPClient with the given User-Agent and default retry settings.
+func NewHTTPClient(userAgent string) *HTTPClient {
+	return &HTTPClient{
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		UserAgent:  userAgent,
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+	}
+}
+
+// HTTPStatusError is returned when a request ends with a non-2xx status.
+// Body holds the start of the response body for callers that decode error payloads.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// maxErrorBody caps how much of a failed response is kept in HTTPStatusError.
+const maxErrorBody = 64 << 10
+
+// Get issues a GET request tied to ctx. On success the caller must close the response body.
+// Errors never contain the query string of rawURL (see redactURL).
+func (c *HTTPClient) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, redactError(err)
+		}
+		if c.UserAgent != "" {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.MaxRetries {
+				return nil, redactError(err)
+			}
+			if err := c.wait(ctx, attempt, ""); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body.Close()
+		statusErr := &HTTPStatusError{URL: redactURL(rawURL), StatusCode: resp.StatusCode, Body: body}
+		if !retryableStatus(resp.StatusCode) || attempt >= c.MaxRetries {
+			return nil, statusErr
+		}
+		fmt.Printf("Retrying %s after status %d (attempt %d of %d)\n", statusErr.URL, resp.StatusCode, attempt+1, c.MaxRetries)
+		if err := c.wait(ctx, attempt, resp.Header.Get("Retry-After")); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait sleeps before the next attempt, preferring the server's Retry-After when present.
+func (c *HTTPClient) wait(ctx context.Context, attempt int, retryAfter string) error {
+	delay, ok := parseRetryAfter(retryAfter)
+	if !ok {
+		delay = c.backoff(attempt)
+	}
+	if c.MaxDelay > 0 && delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns BaseDelay*2^attempt with jitter in [d/2, d).
+func (c *HTTPClient) backoff(attempt int) time.Duration {
+	d := c.BaseDelay << uint(attempt)
+	if d <= 0 || (c.MaxDelay > 0 && d > c.MaxDelay) {
+		d = c.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter accepts either delay-seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// redactURL drops the query string so API keys are not written to logs or errors.
+func redactURL(rawURL string) string {
+	base, _, _ := strings.Cut(rawURL, "?")
+	return base
+}
+
+// redactError returns err with the URL of a *url.Error, as returned by http.Client.Do and
+// url.Parse, replaced by its redacted form.
+func redactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := *urlErr
+	redacted.URL = redactURL(urlErr.URL)
+	return &redacted
+}
@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPClientGetRedactsErrors(t *testing.T) {
+	// A listener that is closed straight away gives a refused connection.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient("test")
+	client.MaxRetries = 0
+	for name, rawURL := range map[string]string{
+		"transport error": "http://" + addr + "/v2/everything?q=good&apiKey=secret123",
+		"status error":    srv.URL + "/v2/everything?q=good&apiKey=secret123",
+		"invalid url":     "http://[::1/v2?apiKey=secret123",
+	} {
+		resp, err := client.Get(context.Background(), rawURL)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: Get succeeded", name)
+			continue
+		}
+		if strings.Contains(err.Error(), "secret123") {
+			t.Errorf("%s: error leaks the API key: %v", name, err)
+		}
+	}
+}
+
+func TestRedactErrorKeepsCause(t *testing.T) {
+	client := NewHTTPClient("test")
+	client.MaxRetries = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.Get(ctx, "http://127.0.0.1:1/?apiKey=secret123")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want it to wrap context.Canceled", err)
+	}
+	if strings.Contains(err.Error(), "secret123") {
+		t.Errorf("error leaks the API key: %v", err)
+	}
+}
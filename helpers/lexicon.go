@@ -0,0 +1,197 @@
+// lexicon.go
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// LexiconRanker is a deterministic, offline ranker that scores articles by counting positive
+// and negative words in the title and excerpt. It is the fallback when no LLM ranker is available.
+type LexiconRanker struct {
+	MaxSelfHelp int // at most this many self-help articles are kept
+}
+
+// NewLexiconRanker returns a LexiconRanker with the same self-help limit as the LLM prompt.
+func NewLexiconRanker() *LexiconRanker {
+	return &LexiconRanker{MaxSelfHelp: 3}
+}
+
+// Name identifies the ranker in logs and run records.
+func (r *LexiconRanker) Name() string {
+	return "lexicon"
+}
+
+// Rank scores the articles and returns the clearly positive ones, best first.
+// Commerce articles are excluded. Ties are broken by input order.
+func (r *LexiconRanker) Rank(ctx context.Context, articles []ArticleWithContent) ([]RankedArticle, error) {
+	type scored struct {
+		art      ArticleWithContent
+		score    float64
+		selfHelp bool
+		politics bool
+		pos, neg int
+	}
+	var candidates []scored
+	for _, art := range articles {
+		words := lexiconWords(art.Title + " " + art.Title + " " + art.Excerpt)
+		if countMatches(words, commerceWords) > 0 {
+			continue
+		}
+		score := lexiconScore(words)
+		if score <= 0 {
+			continue
+		}
+		candidates = append(candidates, scored{
+			art:      art,
+			score:    score,
+			selfHelp: countMatches(words, selfHelpWords) > 0,
+			politics: countMatches(words, politicsWords) > 0,
+			pos:      countMatches(words, positiveWords),
+			neg:      countMatches(words, negativeWords),
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	var ranked []RankedArticle
+	selfHelp := 0
+	for _, c := range candidates {
+		if c.selfHelp {
+			if selfHelp >= r.MaxSelfHelp {
+				continue
+			}
+			selfHelp++
+		}
+		flags := []string{}
+		if c.selfHelp {
+			flags = append(flags, FlagSelfHelp)
+		}
+		if c.politics {
+			flags = append(flags, FlagPolitics)
+		}
+		ranked = append(ranked, RankedArticle{
+			Rank:       len(ranked) + 1,
+			Title:      c.art.Title,
+			URL:        c.art.URL,
+			Category:   lexiconCategory(lexiconWords(c.art.Title + " " + c.art.Excerpt)),
+			Score:      lexiconPercent(c.score),
+			Confidence: lexiconConfidence,
+			Reason:     fmt.Sprintf("Matched %d positive and %d negative keywords.", c.pos, c.neg),
+			Flags:      flags,
+		})
+	}
+	return ranked, nil
+}
+
+// lexiconScore is the net count of positive over negative words, normalized for length.
+func lexiconScore(words []string) float64 {
+	if len(words) == 0 {
+		return 0
+	}
+	net := countMatches(words, positiveWords) - 2*countMatches(words, negativeWords)
+	return float64(net) / math.Sqrt(float64(len(words)))
+}
+
+// lexiconConfidence is reported for every lexicon score; keyword counting is a rough signal.
+const lexiconConfidence = 0.3
+
+// lexiconPercent maps a positive lexicon score onto the 0-100 scale used by LLM rankers.
+// A score of 1 (one net positive word per sqrt(word count)) lands at 100.
+func lexiconPercent(score float64) int {
+	pct := int(math.Round(50 + 50*score))
+	if pct > 100 {
+		pct = 100
+	}
+	return pct
+}
+
+// lexiconCategory picks the category whose keywords appear most often, defaulting to "general".
+func lexiconCategory(words []string) string {
+	best, bestCount := "general", 0
+	for _, category := range lexiconCategoryOrder {
+		if n := countMatches(words, categoryWords[category]); n > bestCount {
+			best, bestCount = category, n
+		}
+	}
+	return best
+}
+
+func countMatches(words []string, lexicon map[string]bool) int {
+	n := 0
+	for _, w := range words {
+		if lexicon[w] {
+			n++
+		}
+	}
+	return n
+}
+
+func lexiconWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-'
+	})
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+var positiveWords = wordSet(
+	"achieve", "achieved", "achievement", "amazing", "benefit", "best", "boost", "brave", "breakthrough",
+	"celebrate", "celebrates", "celebrated", "charity", "cheer", "community", "cure", "cured", "delight",
+	"donate", "donated", "donation", "encouraging", "generous", "gift", "good", "grateful", "happy", "heal",
+	"healing", "heartwarming", "help", "helped", "helping", "hero", "heroes", "hope", "hopeful", "improve",
+	"improved", "innovation", "innovative", "inspire", "inspired", "inspiring", "joy", "kind", "kindness",
+	"milestone", "motivational", "optimism", "optimistic", "progress", "protect", "protected", "recover",
+	"recovered", "recovery", "rescue", "rescued", "restore", "restored", "reunite", "reunited", "save",
+	"saved", "saving", "smile", "succeed", "success", "successful", "support", "thrive", "thriving",
+	"triumph", "uplifting", "volunteer", "volunteers", "win", "wins", "won", "wonderful",
+)
+
+var negativeWords = wordSet(
+	"abuse", "accident", "attack", "attacked", "bomb", "catastrophe", "collapse", "conflict", "crash",
+	"crisis", "dead", "death", "deaths", "died", "disaster", "fatal", "fear", "fraud", "grief", "injured",
+	"kill", "killed", "killing", "lawsuit", "loss", "murder", "outbreak", "protest", "scandal", "shooting",
+	"suicide", "terror", "threat", "tragedy", "tragic", "victim", "victims", "violence", "war",
+)
+
+var commerceWords = wordSet(
+	"buy", "coupon", "deal", "deals", "discount", "discounts", "sale", "sales", "shop", "shopping",
+	"bargain", "promo", "price-drop",
+)
+
+var politicsWords = wordSet(
+	"election", "elections", "senate", "congress", "parliament", "president", "minister", "democrat",
+	"democrats", "republican", "republicans", "campaign", "vote", "voters", "politics", "political",
+)
+
+var selfHelpWords = wordSet(
+	"habits", "mindset", "mindfulness", "motivation", "productivity", "self-care", "self-help",
+	"self-improvement", "affirmations", "manifest",
+)
+
+var lexiconCategoryOrder = []string{
+	"health", "science", "technology", "world", "business", "finance", "sports", "arts", "entertainment", "lifestyle",
+}
+
+var categoryWords = map[string]map[string]bool{
+	"health":        wordSet("health", "medical", "hospital", "patients", "cure", "cancer", "vaccine", "disease", "doctors", "treatment"),
+	"science":       wordSet("science", "scientists", "research", "researchers", "study", "discovery", "species", "space", "climate", "ocean"),
+	"technology":    wordSet("technology", "tech", "ai", "robot", "software", "app", "device", "energy", "solar", "battery"),
+	"world":         wordSet("world", "global", "country", "nations", "government", "international", "refugees", "village"),
+	"business":      wordSet("business", "company", "startup", "entrepreneur", "jobs", "workers", "industry"),
+	"finance":       wordSet("finance", "economy", "investment", "market", "bank", "funding"),
+	"sports":        wordSet("sports", "team", "game", "match", "championship", "athlete", "olympic", "league", "coach"),
+	"arts":          wordSet("art", "arts", "artist", "museum", "music", "painting", "theatre", "theater", "poetry"),
+	"entertainment": wordSet("film", "movie", "tv", "show", "celebrity", "concert", "actor", "actress"),
+	"lifestyle":     wordSet("food", "travel", "family", "garden", "pets", "dog", "cat", "home", "wedding"),
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLexiconRankerOrdersByScore(t *testing.T) {
+	articles := []ArticleWithContent{
+		{URL: "mild", Title: "Town library reopens", Excerpt: "The library reopened after repairs and volunteers helped."},
+		{URL: "glowing", Title: "Volunteers rescue and restore a reef", Excerpt: "An inspiring, hopeful success: volunteers rescued corals and scientists celebrate the recovery of the ocean reef."},
+		{URL: "grim", Title: "Crash leaves victims injured", Excerpt: "A tragic accident on the motorway killed two and injured many."},
+		{URL: "sale", Title: "Amazing deals to celebrate", Excerpt: "Shop the best discount deals and save big during our happy sale."},
+		{URL: "neutral", Title: "Council meets on Tuesday", Excerpt: "The agenda covers road maintenance schedules."},
+		{URL: "mild-again", Title: "Town library reopens", Excerpt: "The library reopened after repairs and volunteers helped."},
+	}
+	ranked, err := NewLexiconRanker().Rank(context.Background(), articles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for i, ra := range ranked {
+		urls = append(urls, ra.URL)
+		if ra.Rank != i+1 {
+			t.Errorf("%s has rank %d, want %d", ra.URL, ra.Rank, i+1)
+		}
+		if ra.Score <= 50 || ra.Score > 100 || ra.Confidence != lexiconConfidence {
+			t.Errorf("%s has score %d and confidence %v", ra.URL, ra.Score, ra.Confidence)
+		}
+		if i > 0 && ra.Score > ranked[i-1].Score {
+			t.Errorf("%s scores %d, above %s at %d", ra.URL, ra.Score, ranked[i-1].URL, ranked[i-1].Score)
+		}
+	}
+	// Negative, neutral and commerce articles are dropped; equal scores keep input order.
+	if want := []string{"glowing", "mild", "mild-again"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("ranked = %v, want %v", urls, want)
+	}
+	if ranked[0].Category != "science" {
+		t.Errorf("category of %s = %q, want science", ranked[0].URL, ranked[0].Category)
+	}
+	if problems := validateRanking(ranked, articles); len(problems) > 0 {
+		t.Errorf("lexicon ranking does not pass LLM validation: %v", problems)
+	}
+}
+
+func TestLexiconRankerFlags(t *testing.T) {
+	articles := []ArticleWithContent{
+		{URL: "habits-1", Title: "Five habits for a happy mindset", Excerpt: "Inspiring motivation tips."},
+		{URL: "habits-2", Title: "Mindfulness habits that inspire", Excerpt: "A hopeful, happy routine."},
+		{URL: "vote", Title: "Voters celebrate a kind election", Excerpt: "The campaign inspired hope and support."},
+	}
+	ranker := &LexiconRanker{MaxSelfHelp: 1}
+	ranked, err := ranker.Rank(context.Background(), articles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := make(map[string][]string)
+	for _, ra := range ranked {
+		flags[ra.URL] = ra.Flags
+	}
+	selfHelp := 0
+	for _, url := range []string{"habits-1", "habits-2"} {
+		if f, ok := flags[url]; ok {
+			selfHelp++
+			if !reflect.DeepEqual(f, []string{FlagSelfHelp}) {
+				t.Errorf("%s flags = %v, want [%s]", url, f, FlagSelfHelp)
+			}
+		}
+	}
+	if selfHelp != 1 {
+		t.Errorf("kept %d self-help articles, want MaxSelfHelp = 1", selfHelp)
+	}
+	if f := flags["vote"]; !reflect.DeepEqual(f, []string{FlagPolitics}) {
+		t.Errorf("vote flags = %v, want [%s]", f, FlagPolitics)
+	}
+}
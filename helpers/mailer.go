@@ -0,0 +1,207 @@
+// mailer.go
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Mailer delivers a digest to subscribers.
+type Mailer interface {
+	Name() string
+	// SendDigest renders and sends the digest to every recipient skip (if not nil) returns false
+	// for. It returns an error only if nothing was delivered; per-recipient failures are listed in
+	// the report.
+	SendDigest(ctx context.Context, digest Digest, skip func(recipient string) bool) (DeliveryReport, error)
+}
+
+// MessageSender sends one rendered message to one address and returns its message ID. The
+// per-recipient mailers implement it; it carries confirmation emails.
+type MessageSender interface {
+	SendMessage(ctx context.Context, to string, email RenderedEmail, headers map[string]string) (string, error)
+}
+
+// Mailers accepted by NewMailer.
+const (
+	MailerSNS  = "sns"
+	MailerSES  = "ses"
+	MailerSMTP = "smtp"
+)
+
+// Delivery is the outcome of sending to one recipient (or, for SNS, to the topic).
+type Delivery struct {
+	Recipient string `json:"recipient"`
+	// RecipientHash replaces Recipient in the sent checkpoint (see sentCheckpoint).
+	RecipientHash string `json:"recipientHash,omitempty"`
+	MessageID     string `json:"messageId,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// DeliveryReport lists every delivery attempted by a Mailer.
+type DeliveryReport struct {
+	Mailer     string     `json:"mailer"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// Failed returns the deliveries that did not go out.
+func (r DeliveryReport) Failed() []Delivery {
+	var failed []Delivery
+	for _, d := range r.Deliveries {
+		if d.Error != "" {
+			failed = append(failed, d)
+		}
+	}
+	return failed
+}
+
+// NewMailer returns the mailer selected by cfg.Mailer.
+func NewMailer(ctx context.Context, cfg Config) (Mailer, error) {
+	renderer, err := NewEmailRenderer(cfg.EmailHTMLTemplate, cfg.EmailTextTemplate)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Mailer {
+	case "", MailerSNS:
+		return &SNSMailer{Config: cfg, Renderer: renderer}, nil
+	case MailerSES:
+		return NewSESMailer(ctx, cfg, renderer)
+	case MailerSMTP:
+		return NewSMTPMailer(cfg, renderer), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
+}
+
+// NewMessageSender returns the configured mailer as a MessageSender, or nil for sns, which can
+// only publish to the whole topic.
+func NewMessageSender(ctx context.Context, cfg Config) (MessageSender, error) {
+	switch cfg.Mailer {
+	case MailerSES:
+		return NewSESMailer(ctx, cfg, nil)
+	case MailerSMTP:
+		return NewSMTPMailer(cfg, nil), nil
+	default:
+		return nil, nil
+	}
+}
+
+// NewDigest returns the template data for a digest of the given articles.
+func NewDigest(cfg Config, articles []ArticleWithContent) Digest {
+	return Digest{
+		Subject:    DigestSubject,
+		Articles:   articles,
+		WebsiteURL: cfg.Website(),
+	}
+}
+
+// recipients returns cfg.EmailRecipients if set, otherwise the active subscribers in the store.
+func recipients(ctx context.Context, cfg Config) ([]Subscriber, error) {
+	if len(cfg.EmailRecipients) > 0 {
+		subs := make([]Subscriber, len(cfg.EmailRecipients))
+		for i, email := range cfg.EmailRecipients {
+			subs[i] = Subscriber{Email: email, Status: SubscriberActive}
+		}
+		return subs, nil
+	}
+	store, err := NewSubscriberStore(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return store.SubscribersByStatus(ctx, SubscriberActive)
+}
+
+// pending returns the recipients skip (if not nil) returns false for.
+func pending(recipients []Subscriber, skip func(recipient string) bool) []Subscriber {
+	if skip == nil {
+		return recipients
+	}
+	var rest []Subscriber
+	for _, sub := range recipients {
+		if !skip(sub.Email) {
+			rest = append(rest, sub)
+		}
+	}
+	return rest
+}
+
+// unsubscribeTokenLifetime is how long the unsubscribe link in a digest keeps working.
+const unsubscribeTokenLifetime = 365 * 24 * time.Hour
+
+// unsubscribeSecret returns the secret that signs unsubscribe links, or "" if cfg.UnsubscribeURL
+// is not set and there are no links to sign.
+func unsubscribeSecret(ctx context.Context, cfg Config) (string, error) {
+	if cfg.UnsubscribeURL == "" {
+		return "", nil
+	}
+	return ConfirmationSecret(ctx, cfg)
+}
+
+// personalize returns the digest as one subscriber should see it, with an unsubscribe link
+// signed with secret.
+func personalize(cfg Config, secret string, digest Digest, sub Subscriber) Digest {
+	digest.Name = sub.Name
+	digest.Articles = sub.FilterArticles(digest.Articles)
+	digest.UnsubscribeURL = unsubscribeLink(cfg, secret, sub.Email)
+	return digest
+}
+
+// unsubscribeLink returns cfg.UnsubscribeURL with a signed token for email added to the query,
+// or "" if none is configured.
+func unsubscribeLink(cfg Config, secret, email string) string {
+	if cfg.UnsubscribeURL == "" {
+		return ""
+	}
+	link, err := url.Parse(cfg.UnsubscribeURL)
+	if err != nil {
+		return "" // rejected by Validate
+	}
+	query := link.Query()
+	query.Set("token", SignToken(secret, TokenUnsubscribe, email, time.Now().Add(unsubscribeTokenLifetime)))
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// unsubscribeHeaders returns the List-Unsubscribe headers for a message with the link, including
+// List-Unsubscribe-Post so mail clients can unsubscribe with one click (RFC 8058).
+func unsubscribeHeaders(link string) map[string]string {
+	if link == "" {
+		return nil
+	}
+	return map[string]string{
+		"List-Unsubscribe":      "<" + link + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// SNSMailer publishes the plain-text part of the digest once to the SNS topic, which fans it
+// out to every confirmed email subscription. SNS cannot send HTML or personalise messages.
+type SNSMailer struct {
+	Config   Config
+	Renderer *EmailRenderer
+}
+
+// Name returns "sns".
+func (m *SNSMailer) Name() string {
+	return MailerSNS
+}
+
+// SendDigest publishes the digest to the topic, unless skip reports the topic as done.
+func (m *SNSMailer) SendDigest(ctx context.Context, digest Digest, skip func(recipient string) bool) (DeliveryReport, error) {
+	report := DeliveryReport{Mailer: m.Name()}
+	if skip != nil && skip(m.Config.SNSTopicARN) {
+		return report, nil
+	}
+	email, err := m.Renderer.Render(digest)
+	if err != nil {
+		return report, err
+	}
+	messageID, err := SendEmail(ctx, m.Config, email.Subject, email.Text)
+	delivery := Delivery{Recipient: m.Config.SNSTopicARN, MessageID: messageID}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	report.Deliveries = append(report.Deliveries, delivery)
+	return report, err
+}
@@ -0,0 +1,59 @@
+// mime.go
+package helpers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"sort"
+	"time"
+)
+
+// BuildMIMEMessage encodes the email as a multipart/alternative message with a plain-text and an
+// HTML part, both quoted-printable. extra headers (e.g. List-Unsubscribe) are added as given.
+func BuildMIMEMessage(from, to string, email RenderedEmail, extra map[string]string) []byte {
+	var boundary [12]byte
+	rand.Read(boundary[:])
+	b := "alt-" + hex.EncodeToString(boundary[:])
+
+	headers := map[string]string{
+		"From":         from,
+		"To":           to,
+		"Subject":      mime.QEncoding.Encode("utf-8", email.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"MIME-Version": "1.0",
+		"Content-Type": fmt.Sprintf("multipart/alternative; boundary=%q", b),
+	}
+	for k, v := range extra {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, k := range names {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, headers[k])
+	}
+	buf.WriteString("\r\n")
+	writeMIMEPart(&buf, b, "text/plain; charset=utf-8", email.Text)
+	writeMIMEPart(&buf, b, "text/html; charset=utf-8", email.HTML)
+	fmt.Fprintf(&buf, "--%s--\r\n", b)
+	return buf.Bytes()
+}
+
+func writeMIMEPart(buf *bytes.Buffer, boundary, contentType, body string) {
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	fmt.Fprintf(buf, "Content-Type: %s\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(buf)
+	qp.Write([]byte(body))
+	qp.Close()
+	buf.WriteString("\r\n")
+}
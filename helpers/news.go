@@ -2,11 +2,11 @@
 package helpers
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -14,111 +14,181 @@ import (
 	"github.com/go-shiori/go-readability"
 )
 
-// fetchNews retrieves up to 50 articles from NewsAPI for a given page.
-func FetchNews(apiKey string, page int) ([]Article, error) {
-	query := "inspiring OR heartwarming OR motivational OR encouraging OR breakthrough OR innovation OR success OR 'good news' OR uplifting OR inspiring -crisis -war -tragedy -disaster -shooting"
-	today := time.Now()
-	sevenDaysAgo := today.AddDate(0, 0, -7)
-	fromDate := sevenDaysAgo.Format("2006-01-02")
-	toDate := today.Format("2006-01-02")
-	requestURL := fmt.Sprintf("%s?q=%s&from=%s&to=%s&sortBy=relevancy&pageSize=50&page=%d&language=en&apiKey=%s",
-		NewsAPIURL, url.QueryEscape(query), fromDate, toDate, page, apiKey)
-	resp, err := http.Get(requestURL)
+// maxArticleSize caps how much of an article page is read.
+const maxArticleSize = 5 << 20
+
+// FetchArticlePage downloads an article and returns its text content together with the
+// canonical URL of the page: its rel=canonical link if declared, otherwise the final URL after redirects.
+// The URL is returned as the page gives it, not normalized by CanonicalURL, so it can be linked to.
+// A nil client uses DefaultHTTPClient.
+func FetchArticlePage(ctx context.Context, client *HTTPClient, articleURL string) (content, canonicalURL string, err error) {
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	resp, err := client.Get(ctx, articleURL)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	page, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxArticleSize))
 	if err != nil {
-		return nil, err
-	}
-	// fmt.Printf("NewsAPI Raw Output (page %d):\n", page)
-	// fmt.Println(string(body))
-	var newsResp NewsResponse
-	if err := json.Unmarshal(body, &newsResp); err != nil {
-		return nil, err
+		return "", "", err
 	}
-	return newsResp.Articles, nil
-}
-
-// fetchArticleContent downloads the article content from a URL.
-func FetchArticleContent(articleURL string) (string, error) {
-	resp, err := http.Get(articleURL)
-	if err != nil {
-		return "", err
+	parsedURL := resp.Request.URL
+	if parsedURL == nil {
+		if parsedURL, err = url.Parse(articleURL); err != nil {
+			return "", "", err
+		}
 	}
-	defer resp.Body.Close()
-	parsedURL, err := url.Parse(articleURL)
-	if err != nil {
-		return "", err
+	canonicalURL = findCanonicalLink(page, parsedURL)
+	if canonicalURL == "" {
+		canonicalURL = parsedURL.String()
 	}
-	doc, err := readability.FromReader(resp.Body, parsedURL)
+	doc, err := readability.FromReader(bytes.NewReader(page), parsedURL)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	return doc.TextContent, nil
+	return doc.TextContent, canonicalURL, nil
 }
 
-// getFiftyWordExcerpt returns the first 50 words of the given text.
-func GetFiftyWordExcerpt(text string) string {
+// getExcerpt returns the first n words of the given text, or "" if it is shorter.
+func GetExcerpt(text string, n int) string {
 	words := strings.Fields(text)
-	if len(words) < 50 {
+	if len(words) < n {
 		return ""
 	}
-	return strings.Join(words[:50], " ")
+	return strings.Join(words[:n], " ")
 }
 
-// accumulateValidArticles fetches and filters articles until up to 30 valid ones are accumulated.
-func AccumulateValidArticles(ctx context.Context, apiKey string, recentMap map[string]bool) ([]ArticleWithContent, error) {
+// accumulateValidArticles fetches candidates from all sources and filters them until cfg.TargetArticles valid ones
+// are accumulated or cfg.MaxPages pages have been read. Article content is downloaded concurrently within the
+// configured limits. URLs found in history are skipped, near-duplicates of a recently sent story
+// (recentFingerprints) are dropped, and near-duplicates within the run collapse to one representative.
+// CanonicalURL is only the dedup and history key; articles keep the URL they were found under, or
+// the page's own canonical link, for fetching and linking.
+// A nil or failing history skips the already-sent check.
+func AccumulateValidArticles(ctx context.Context, cfg Config, sources []NewsSource, history SentHistory, recentFingerprints []uint64) ([]ArticleWithContent, error) {
+	if history == nil {
+		history = RecentURLs(nil)
+	}
+	opts := cfg.ExtractOptions()
+	target := cfg.TargetArticles
 	var validArticles []ArticleWithContent
 	seen := make(map[string]bool)
 	attempts := 0
-	maxAttempts := 3
+	maxAttempts := cfg.MaxPages
 	page := 1
-	for len(validArticles) < 30 && attempts < maxAttempts {
-		articles, err := FetchNews(apiKey, page)
+	to := time.Now()
+	from := to.AddDate(0, 0, -cfg.LookbackDays)
+	for len(validArticles) < target && attempts < maxAttempts {
+		articles, err := FetchCandidates(ctx, sources, from, to, page)
 		if err != nil {
 			return nil, err
 		}
+		var fresh []Article
+		var freshURLs []string
 		for _, art := range articles {
-			if seen[art.URL] {
+			art.URL = strings.TrimSpace(art.URL)
+			key := CanonicalURL(art.URL)
+			if seen[key] {
 				continue
 			}
-			if recentMap != nil {
-				if _, exists := recentMap[art.URL]; exists {
-					continue
-				}
+			seen[key] = true
+			fresh = append(fresh, art)
+			freshURLs = append(freshURLs, key)
+		}
+		sent, err := history.AlreadySent(ctx, freshURLs)
+		if err != nil {
+			fmt.Println("Error checking sent history:", err)
+		}
+		var candidates []Article
+		var urls []string
+		for i, art := range fresh {
+			if !sent[freshURLs[i]] {
+				candidates = append(candidates, art)
+				urls = append(urls, art.URL)
 			}
-			content, err := FetchArticleContent(art.URL)
-			if err != nil {
-				fmt.Printf("Error fetching content for article '%s': %v\n", art.Title, err)
+		}
+		contents := FetchArticleContents(ctx, urls, opts)
+
+		// The page's own canonical link can reveal an AMP or syndicated copy of something already sent.
+		var relinked []string
+		for i, art := range candidates {
+			if c := CanonicalURL(contents[i].CanonicalURL); contents[i].Err == nil && c != "" && c != CanonicalURL(art.URL) {
+				relinked = append(relinked, c)
+			}
+		}
+		sentCanonical, err := history.AlreadySent(ctx, relinked)
+		if err != nil {
+			fmt.Println("Error checking sent history:", err)
+		}
+
+		for i, art := range candidates {
+			if contents[i].Err != nil {
+				fmt.Printf("Error fetching content for article '%s': %v\n", art.Title, contents[i].Err)
 				continue
 			}
+			if canonical := contents[i].CanonicalURL; canonical != "" {
+				if key := CanonicalURL(canonical); key != CanonicalURL(art.URL) {
+					if seen[key] || sentCanonical[key] {
+						continue
+					}
+					seen[key] = true
+					art.URL = canonical
+				}
+			}
+			content := contents[i].Content
 			words := strings.Fields(content)
-			if len(words) < 150 {
+			if len(words) < cfg.MinWords {
 				continue
 			}
-			excerpt := GetFiftyWordExcerpt(content)
+			excerpt := GetExcerpt(content, cfg.ExcerptWords)
 			if excerpt == "" {
 				continue
 			}
-			validArticles = append(validArticles, ArticleWithContent{
-				Title:    art.Title,
-				URL:      art.URL,
-				Excerpt:  excerpt,
-				ImageURL: art.ImageURL,
-			})
-			seen[art.URL] = true
-			if len(validArticles) >= 30 {
+			candidate := ArticleWithContent{
+				Title:       art.Title,
+				URL:         art.URL,
+				Excerpt:     excerpt,
+				ImageURL:    art.ImageURL,
+				Fingerprint: Fingerprint(art.Title, excerpt),
+			}
+			if MatchesAny(candidate.Fingerprint, recentFingerprints) {
+				fmt.Printf("Skipping '%s': near-duplicate of a recently sent article\n", art.Title)
+				continue
+			}
+			if dup := nearDuplicateIndex(validArticles, candidate.Fingerprint); dup >= 0 {
+				if betterRepresentative(candidate, validArticles[dup]) {
+					validArticles[dup] = candidate
+				}
+				continue
+			}
+			validArticles = append(validArticles, candidate)
+			if len(validArticles) >= target {
 				break
 			}
 		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		attempts++
 		page++
-		if len(validArticles) < 30 && attempts < maxAttempts {
+		if len(validArticles) < target && attempts < maxAttempts {
 			fmt.Printf("Accumulated %d valid articles so far; fetching again (attempt %d of %d, page %d)...\n", len(validArticles), attempts+1, maxAttempts, page)
-			time.Sleep(2 * time.Second)
+			if err := sleepContext(ctx, 2*time.Second); err != nil {
+				return nil, err
+			}
 		}
 	}
 	return validArticles, nil
 }
+
+// nearDuplicateIndex returns the index of the first article near-duplicating fp, or -1.
+func nearDuplicateIndex(articles []ArticleWithContent, fp uint64) int {
+	for i, art := range articles {
+		if IsNearDuplicate(art.Fingerprint, fp) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// staticSource returns the same articles on page 1 and nothing after.
+type staticSource []Article
+
+func (s staticSource) Name() string { return "static" }
+
+func (s staticSource) FetchPage(ctx context.Context, from, to time.Time, page int) ([]Article, error) {
+	if page > 1 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// articlePage is an HTML page with enough text to pass the word filters.
+func articlePage(title, canonical, sentence string) string {
+	var link string
+	if canonical != "" {
+		link = fmt.Sprintf(`<link rel="canonical" href="%s">`, canonical)
+	}
+	body := strings.Repeat(sentence+" ", 40)
+	return fmt.Sprintf(`<html><head><title>%s</title>%s</head><body><article><h1>%s</h1><p>%s</p></article></body></html>`,
+		title, link, title, body)
+}
+
+func TestAccumulateValidArticlesKeepsRealURLs(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/story/amp", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlePage("Trees", "", "Volunteers planted trees and the whole town came out to help them."))
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlePage("Garden", srv.URL+"/garden-story?ref=home", "A disused car park became a flower garden where neighbours now meet for lunch."))
+	})
+
+	cfg := DefaultConfig()
+	cfg.TargetArticles = 5
+	cfg.MaxPages = 1
+	cfg.MinWords = 100
+	cfg.ExcerptWords = 20
+	src := staticSource{
+		{Title: "Trees", URL: srv.URL + "/story/amp"},
+		{Title: "Trees again", URL: srv.URL + "/story"}, // same story once canonicalized
+		{Title: "Garden", URL: srv.URL + "/other"},
+	}
+	got, err := AccumulateValidArticles(context.Background(), cfg, []NewsSource{src}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, art := range got {
+		urls = append(urls, art.URL)
+	}
+	want := []string{srv.URL + "/story/amp", srv.URL + "/garden-story?ref=home"}
+	if strings.Join(urls, " ") != strings.Join(want, " ") {
+		t.Errorf("URLs = %v, want %v", urls, want)
+	}
+}
+
+func TestAccumulateValidArticlesStopsWaitingWhenCancelled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetArticles = 1
+	cfg.MaxPages = 3
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := AccumulateValidArticles(ctx, cfg, []NewsSource{staticSource{}}, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v, want soon after the deadline", elapsed)
+	}
+}
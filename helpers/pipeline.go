@@ -0,0 +1,272 @@
+// pipeline.go
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GenerateDigest runs the full content generation workflow: fetch, filter, rank, publish, send and record.
+// Unless this is a dry run it holds the day's RunLock throughout, returning a *RunLockError without
+// doing anything if the day's digest was already sent or is being generated. The lease lasts until
+// just after ctx's deadline (cfg.RunLockLease() without one), so a retry of a timed-out invocation
+// can resume the run.
+func GenerateDigest(ctx context.Context, cfg Config) error {
+	now := time.Now()
+	run := DigestRun{RunID: NewRunID(now), StartedAt: now}
+
+	// Open the configured article store.
+	store, err := NewArticleStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error opening article store: %w", err)
+	}
+	if cfg.DryRun {
+		fmt.Println("Run ID:", run.RunID, "(dry run)")
+		return generateDigest(ctx, cfg, store, run)
+	}
+
+	lock, err := store.AcquireRunLock(ctx, now, run.RunID, runLockLease(ctx, cfg.RunLockLease()), cfg.ForceRun)
+	if err != nil {
+		return err
+	}
+	run.RunID = lock.RunID
+	fmt.Println("Run ID:", run.RunID)
+
+	// Stop generating a little before the deadline so there is time left to release the lock.
+	workCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		workCtx, cancel = context.WithDeadline(ctx, deadline.Add(-runLockReleaseReserve))
+		defer cancel()
+	}
+	err = generateDigest(workCtx, cfg, store, run)
+	status := RunStatusSent
+	if err != nil {
+		status = RunStatusFailed
+	}
+	// Release even if ctx is done, so a retry can take over at once.
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runLockReleaseReserve)
+	defer cancel()
+	if releaseErr := store.ReleaseRunLock(releaseCtx, lock, status); releaseErr != nil {
+		fmt.Println("Error releasing run lock:", releaseErr)
+	}
+	return err
+}
+
+func generateDigest(ctx context.Context, cfg Config, store ArticleStore, run DigestRun) error {
+	// Dry runs get a fresh run ID every time, so there is nothing to resume.
+	var checkpoints *Checkpoints
+	if !cfg.DryRun {
+		cp, err := NewCheckpoints(ctx, cfg, run.RunID)
+		if err != nil {
+			fmt.Println("Error opening checkpoints, continuing without them:", err)
+		}
+		checkpoints = cp
+	}
+
+	// Retrieve secrets (NewsAPI & OpenAI keys)
+	newsAPIKey, openaiAPIKey, err := GetSecrets(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error retrieving secrets: %w", err)
+	}
+
+	var validArticles []ArticleWithContent
+	if !checkpoints.Load(ctx, checkpointArticles, &validArticles) {
+		validArticles, err = FetchStage(ctx, cfg, store, checkpoints.Sources(NewsSources(cfg, newsAPIKey)))
+		if err != nil {
+			return err
+		}
+		checkpoints.Save(ctx, checkpointArticles, validArticles)
+	}
+
+	var ranking rankingCheckpoint
+	if !checkpoints.Load(ctx, checkpointRanking, &ranking) {
+		ranking.Ranked, ranking.Ranker, err = RankStage(ctx, cfg, openaiAPIKey, validArticles)
+		if err != nil {
+			return err
+		}
+		checkpoints.Save(ctx, checkpointRanking, ranking)
+	}
+	run.Ranker = ranking.Ranker
+
+	var rendered messageCheckpoint
+	if !checkpoints.Load(ctx, checkpointMessage, &rendered) {
+		// Select the top articles that clear the minimum positivity score.
+		rendered.Articles = SelectTopArticles(ranking.Ranked, validArticles, cfg.MinScore, cfg.TopArticles)
+
+		// Generate a pre-signed URL for latest_news.json.
+		rendered.PreSignedURL, err = GeneratePreSignedURL(ctx, cfg)
+		if err != nil {
+			fmt.Println("Error generating pre-signed URL:", err)
+			rendered.PreSignedURL = "Unavailable"
+		}
+
+		// Render the HTML and plain-text email.
+		rendered.Email, err = RenderDigest(cfg, rendered.Articles)
+		if err != nil {
+			return err
+		}
+		checkpoints.Save(ctx, checkpointMessage, rendered)
+	}
+	topArticles := rendered.Articles
+
+	if cfg.DryRun {
+		return writeDryRun(ctx, cfg, run, ranking.Ranked, topArticles, rendered.PreSignedURL, rendered.Email)
+	}
+
+	for _, art := range topArticles {
+		run.ArticleURLs = append(run.ArticleURLs, art.URL)
+	}
+
+	// A resumed run whose email already went out only has to record it; one that reached only
+	// some recipients sends to the rest.
+	var saved DigestRun
+	resumed := checkpoints.Load(ctx, checkpointSent, &saved)
+	if resumed && saved.SendStatus != RunStatusFailed {
+		run = saved
+		fmt.Println("Digest was already sent by this run; recording it")
+	} else {
+		if !resumed {
+			// Update index.html with the new pre-signed URL.
+			if err := UpdateIndexHTML(ctx, cfg, rendered.PreSignedURL); err != nil {
+				fmt.Println("Error updating index.html:", err)
+			}
+		}
+
+		mailer, err := NewMailer(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("error creating mailer: %w", err)
+		}
+		saved.RunID = run.RunID
+		report, err := mailer.SendDigest(ctx, NewDigest(cfg, topArticles), deliveredTo(saved))
+		run.Mailer = report.Mailer
+		run.Deliveries = append(delivered(saved), report.Deliveries...)
+		if failed := report.Failed(); err == nil && len(failed) > 0 {
+			err = fmt.Errorf("%d of %d deliveries failed", len(failed), len(report.Deliveries))
+		}
+		if err != nil {
+			// Keep who has the digest, so the resumed run only sends to the rest.
+			run.SendStatus = RunStatusFailed
+			run.Error = err.Error()
+			checkpoints.Save(ctx, checkpointSent, sentCheckpoint(run))
+			if saveErr := store.SaveRun(ctx, run); saveErr != nil {
+				fmt.Println("Error recording digest run:", saveErr)
+			}
+			return fmt.Errorf("error sending email via %s: %w", mailer.Name(), err)
+		}
+		run.SendStatus = RunStatusSent
+		run.Error = ""
+		checkpoints.Save(ctx, checkpointSent, sentCheckpoint(run))
+	}
+
+	// Persist what was sent so the no-repeat rule applies to future runs.
+	if err := store.StoreArticles(ctx, run.RunID, topArticles); err != nil {
+		fmt.Println("Error storing sent articles:", err)
+		run.Error = strings.TrimPrefix(run.Error+"; ", "; ") + fmt.Sprintf("storing articles: %v", err)
+	}
+	run.SendStatus = RunStatusSent
+	if err := store.SaveRun(ctx, run); err != nil {
+		fmt.Println("Error recording digest run:", err)
+	}
+
+	fmt.Println("Content generation and email delivery completed successfully!")
+	return nil
+}
+
+// NewsSources returns the configured news sources: NewsAPI and the RSS/Atom feeds.
+func NewsSources(cfg Config, newsAPIKey string) []NewsSource {
+	return []NewsSource{
+		NewNewsAPISource(cfg, newsAPIKey),
+		NewFeedSource(cfg),
+	}
+}
+
+// FetchStage collects valid candidate articles from the sources, skipping anything the
+// store says was sent recently.
+func FetchStage(ctx context.Context, cfg Config, store ArticleStore, sources []NewsSource) ([]ArticleWithContent, error) {
+	// Fingerprints of recently sent articles catch re-sends under a different URL.
+	var history SentHistory
+	var recentFingerprints []uint64
+	if store != nil {
+		history = store
+		recentArticles, err := store.RecentArticles(ctx, cfg.HistoryCutoff())
+		if err != nil {
+			fmt.Println("Error fetching recent articles from the store:", err)
+		}
+		recentFingerprints = Fingerprints(recentArticles)
+	}
+
+	validArticles, err := AccumulateValidArticles(ctx, cfg, sources, history, recentFingerprints)
+	if err != nil {
+		return nil, fmt.Errorf("error accumulating valid articles: %w", err)
+	}
+	fmt.Printf("Total valid articles accumulated: %d\n", len(validArticles))
+	return validArticles, nil
+}
+
+// RankStage ranks the articles with the configured LLM, falling back to the offline lexicon
+// ranker if the LLM call fails. It returns the ranking and the name of the ranker used.
+func RankStage(ctx context.Context, cfg Config, openaiAPIKey string, articles []ArticleWithContent) ([]RankedArticle, string, error) {
+	rankers := []Ranker{NewRanker(cfg, openaiAPIKey), NewLexiconRanker()}
+	rankedArticles, rankerName, err := RankWithFallback(ctx, rankers, articles)
+	if err != nil {
+		return nil, "", fmt.Errorf("error ranking articles: %w", err)
+	}
+	fmt.Printf("Ranking from %s:\n", rankerName)
+	for _, ra := range rankedArticles {
+		fmt.Printf("Rank %d: %s (%s) - Category: %s, Score: %d, Confidence: %.2f, Flags: %v\n  %s\n",
+			ra.Rank, ra.Title, ra.URL, ra.Category, ra.Score, ra.Confidence, ra.Flags, ra.Reason)
+	}
+	return rankedArticles, rankerName, nil
+}
+
+// dryRunReport is the ranking output written by a dry run.
+type dryRunReport struct {
+	RunID    string               `json:"runId"`
+	Ranker   string               `json:"ranker"`
+	Ranked   []RankedArticle      `json:"ranked"`
+	Selected []ArticleWithContent `json:"selected"`
+}
+
+type artifact struct {
+	name, contentType string
+	data              []byte
+}
+
+// writeDryRun saves the ranking, both parts of the rendered email and the would-be index.html through an
+// ArtifactWriter instead of publishing, sending or recording anything.
+func writeDryRun(ctx context.Context, cfg Config, run DigestRun, ranked []RankedArticle, selected []ArticleWithContent, preSignedURL string, email RenderedEmail) error {
+	artifacts, err := NewArtifactStore(ctx, cfg, cfg.DryRunDir, cfg.DryRunPrefix, run.RunID)
+	if err != nil {
+		return fmt.Errorf("error opening dry run output: %w", err)
+	}
+
+	report, err := json.MarshalIndent(dryRunReport{RunID: run.RunID, Ranker: run.Ranker, Ranked: ranked, Selected: selected}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ranking: %w", err)
+	}
+	files := []artifact{
+		{"ranking.json", "application/json", report},
+		{"email.txt", "text/plain; charset=utf-8", []byte("Subject: " + email.Subject + "\n\n" + email.Text)},
+		{"email.html", "text/html; charset=utf-8", []byte(email.HTML)},
+	}
+
+	// The live index.html is only read, never overwritten.
+	if indexHTML, err := RenderIndexHTML(ctx, cfg, preSignedURL); err != nil {
+		fmt.Println("Error rendering index.html:", err)
+	} else {
+		files = append(files, artifact{cfg.IndexKey, "text/html", []byte(indexHTML)})
+	}
+
+	for _, f := range files {
+		if err := artifacts.WriteArtifact(ctx, f.name, f.data, f.contentType); err != nil {
+			return err
+		}
+		fmt.Println("Dry run wrote", artifacts.Location(f.name))
+	}
+	fmt.Println("Dry run completed; nothing was published or sent.")
+	return nil
+}
@@ -5,17 +5,276 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
-// rankArticlesWithChatGPT sends up to 30 articles to GPT-4 for ranking.
-func RankArticlesWithChatGPT(ctx context.Context, client *openai.Client, articles []ArticleWithContent) ([]RankedArticle, error) {
-	if len(articles) > 30 {
-		articles = articles[:30]
+// Ranker orders candidate articles from most to least positive, dropping those that are not clearly positive.
+type Ranker interface {
+	Name() string
+	Rank(ctx context.Context, articles []ArticleWithContent) ([]RankedArticle, error)
+}
+
+// DefaultOpenAIModel is the chat model used when none is configured.
+// It must support JSON-schema structured outputs.
+const DefaultOpenAIModel = "gpt-4o"
+
+// OpenAIRanker ranks articles with a chat completion model. It works against the OpenAI API
+// or any server implementing the OpenAI chat completions endpoint.
+type OpenAIRanker struct {
+	Client      *openai.Client
+	Model       string
+	Temperature *float32 // nil leaves the server default in place
+	MaxRepairs  int      // re-prompts allowed after an invalid response
+	name        string
+}
+
+// NewOpenAIRanker returns a ranker backed by the OpenAI API. An empty baseURL uses the public endpoint.
+func NewOpenAIRanker(apiKey, model string, temperature *float32, baseURL string) *OpenAIRanker {
+	cfg := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAIRanker{
+		Client:      openai.NewClientWithConfig(cfg),
+		Model:       model,
+		Temperature: temperature,
+		MaxRepairs:  2,
+		name:        "openai",
+	}
+}
+
+// NewOpenAICompatibleRanker returns a ranker for a self-hosted server exposing the OpenAI
+// chat completions API (for example vLLM, Ollama or llama.cpp). apiKey may be empty.
+func NewOpenAICompatibleRanker(baseURL, apiKey, model string, temperature *float32) *OpenAIRanker {
+	r := NewOpenAIRanker(apiKey, model, temperature, baseURL)
+	r.name = "openai-compatible"
+	return r
+}
+
+// Name identifies the ranker in logs and run records.
+func (r *OpenAIRanker) Name() string {
+	if r.name == "" {
+		return "openai"
+	}
+	return r.name
+}
+
+// Rank sends the articles to the model and validates the structured ranking it returns.
+// Invalid output is sent back to the model for repair up to MaxRepairs times before a
+// *RankingValidationError is returned.
+func (r *OpenAIRanker) Rank(ctx context.Context, articles []ArticleWithContent) ([]RankedArticle, error) {
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "You are an expert sentiment analyst who curates news to inspire global positivity.",
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: buildRankingPrompt(articles),
+		},
+	}
+	var lastErr error
+	for attempt := 0; attempt <= r.MaxRepairs; attempt++ {
+		req := openai.ChatCompletionRequest{
+			Model:       r.Model,
+			Temperature: requestTemperature(r.Temperature),
+			Messages:    messages,
+			ResponseFormat: &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+				JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+					Name:   "article_ranking",
+					Schema: rankingSchema,
+					Strict: true,
+				},
+			},
+		}
+		resp, err := r.Client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("%s returned no choices", r.Name())
+		}
+		msg := resp.Choices[0].Message
+		if msg.Refusal != "" {
+			return nil, fmt.Errorf("%s refused to rank: %s", r.Name(), msg.Refusal)
+		}
+		ranked, err := parseRanking(msg.Content, articles)
+		if err == nil {
+			return ranked, nil
+		}
+		lastErr = err
+		fmt.Printf("Invalid ranking from %s (attempt %d of %d): %v\n", r.Name(), attempt+1, r.MaxRepairs+1, err)
+		messages = append(messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: msg.Content},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: repairPrompt(err)},
+		)
+	}
+	return nil, lastErr
+}
+
+// requestTemperature converts a configured temperature for openai.ChatCompletionRequest, whose
+// Temperature field is omitted when zero. An explicit 0 is sent as the smallest non-zero float32,
+// which the server treats as 0, and nil becomes 0 so the field is left out.
+func requestTemperature(t *float32) float32 {
+	switch {
+	case t == nil:
+		return 0
+	case *t == 0:
+		return math.SmallestNonzeroFloat32
+	default:
+		return *t
+	}
+}
+
+// RankingCategories are the categories a ranker may assign.
+var RankingCategories = []string{"business", "entertainment", "general", "health", "science", "sports", "technology", "finance", "world", "arts", "lifestyle"}
+
+var rankingFlags = []string{FlagCommerce, FlagSelfHelp, FlagPolitics}
+
+// rankingSchema is the strict JSON schema the model's response must follow.
+var rankingSchema = &jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"articles": {
+			Type: jsonschema.Array,
+			Items: &jsonschema.Definition{
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"rank":       {Type: jsonschema.Integer, Description: "Position from 1 (most positive) to N."},
+					"title":      {Type: jsonschema.String},
+					"url":        {Type: jsonschema.String, Description: "The article URL exactly as given."},
+					"category":   {Type: jsonschema.String, Enum: RankingCategories},
+					"score":      {Type: jsonschema.Integer, Description: "Positivity from 0 to 100."},
+					"confidence": {Type: jsonschema.Number, Description: "Confidence in the score from 0 to 1."},
+					"reason":     {Type: jsonschema.String, Description: "One sentence explaining the ranking."},
+					"flags": {
+						Type:  jsonschema.Array,
+						Items: &jsonschema.Definition{Type: jsonschema.String, Enum: rankingFlags},
+					},
+				},
+				Required:             []string{"rank", "title", "url", "category", "score", "confidence", "reason", "flags"},
+				AdditionalProperties: false,
+			},
+		},
+	},
+	Required:             []string{"articles"},
+	AdditionalProperties: false,
+}
+
+// RankingValidationError reports a ranking that is not valid JSON or violates the schema or input.
+type RankingValidationError struct {
+	Problems []string
+	Raw      string
+}
+
+func (e *RankingValidationError) Error() string {
+	return fmt.Sprintf("invalid ranking: %s", strings.Join(e.Problems, "; "))
+}
+
+// parseRanking decodes the model output and checks it against the input articles.
+func parseRanking(raw string, articles []ArticleWithContent) ([]RankedArticle, error) {
+	var payload struct {
+		Articles []RankedArticle `json:"articles"`
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&payload); err != nil {
+		return nil, &RankingValidationError{Problems: []string{fmt.Sprintf("not valid JSON for the schema: %v", err)}, Raw: raw}
+	}
+	if problems := validateRanking(payload.Articles, articles); len(problems) > 0 {
+		return nil, &RankingValidationError{Problems: problems, Raw: raw}
 	}
-	prompt := "Below are up to 30 articles with their title, URL, and a short excerpt (first 50 words of the article body). " +
+	return payload.Articles, nil
+}
+
+// validateRanking checks that ranks run 1..N, every URL is one of the inputs and appears once,
+// and categories and scores are in range. Repeated flags are dropped in place, since flags are
+// stored as a DynamoDB string set.
+func validateRanking(ranked []RankedArticle, articles []ArticleWithContent) []string {
+	inputs := make(map[string]bool, len(articles))
+	for _, art := range articles {
+		inputs[CanonicalURL(art.URL)] = true
+	}
+	categories := make(map[string]bool, len(RankingCategories))
+	for _, c := range RankingCategories {
+		categories[c] = true
+	}
+	var problems []string
+	seen := make(map[string]bool)
+	for i, ra := range ranked {
+		if ra.Rank != i+1 {
+			problems = append(problems, fmt.Sprintf("element %d has rank %d, expected %d", i+1, ra.Rank, i+1))
+		}
+		url := CanonicalURL(ra.URL)
+		if !inputs[url] {
+			problems = append(problems, fmt.Sprintf("url %q is not one of the given articles", ra.URL))
+		} else if seen[url] {
+			problems = append(problems, fmt.Sprintf("url %q appears more than once", ra.URL))
+		}
+		seen[url] = true
+		if !categories[ra.Category] {
+			problems = append(problems, fmt.Sprintf("rank %d has unknown category %q", ra.Rank, ra.Category))
+		}
+		if ra.Score < 0 || ra.Score > 100 {
+			problems = append(problems, fmt.Sprintf("rank %d has score %d outside 0-100", ra.Rank, ra.Score))
+		}
+		if ra.Confidence < 0 || ra.Confidence > 1 {
+			problems = append(problems, fmt.Sprintf("rank %d has confidence %g outside 0-1", ra.Rank, ra.Confidence))
+		}
+		ranked[i].Flags = uniqueStrings(ra.Flags)
+		for _, flag := range ranked[i].Flags {
+			if flag != FlagCommerce && flag != FlagSelfHelp && flag != FlagPolitics {
+				problems = append(problems, fmt.Sprintf("rank %d has unknown flag %q", ra.Rank, flag))
+			}
+		}
+	}
+	return problems
+}
+
+// repairPrompt asks the model to correct its previous answer.
+func repairPrompt(err error) string {
+	return fmt.Sprintf("Your previous answer was invalid: %v. "+
+		"Return the corrected ranking, using only URLs copied exactly from the article list.", err)
+}
+
+// NewRanker builds the configured LLM ranker. Setting cfg.RankerBaseURL points it at a
+// self-hosted OpenAI-compatible server instead of the OpenAI API.
+func NewRanker(cfg Config, openaiAPIKey string) Ranker {
+	if cfg.RankerBaseURL != "" {
+		return NewOpenAICompatibleRanker(cfg.RankerBaseURL, cfg.RankerAPIKey, cfg.RankerModel, cfg.RankerTemperature)
+	}
+	return NewOpenAIRanker(openaiAPIKey, cfg.RankerModel, cfg.RankerTemperature, "")
+}
+
+// RankWithFallback tries each ranker in order and returns the first successful ranking
+// together with the name of the ranker that produced it.
+func RankWithFallback(ctx context.Context, rankers []Ranker, articles []ArticleWithContent) ([]RankedArticle, string, error) {
+	var lastErr error
+	for _, r := range rankers {
+		ranked, err := r.Rank(ctx, articles)
+		if err == nil {
+			return ranked, r.Name(), nil
+		}
+		fmt.Printf("Ranker %s failed: %v\n", r.Name(), err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return nil, "", fmt.Errorf("no rankers configured")
+	}
+	return nil, "", fmt.Errorf("all rankers failed, last error: %w", lastErr)
+}
+
+// buildRankingPrompt lists the articles after the ranking instructions.
+func buildRankingPrompt(articles []ArticleWithContent) string {
+	prompt := fmt.Sprintf("Below are %d articles with their title, URL, and a short excerpt (the start of the article body). ", len(articles)) +
 		"Please analyze them and rank the articles from most positive to least positive, ensuring that the reader feels optimistic about the world. " +
 		"Important: Only include an article if it is clearly positive. If fewer than 10 articles are clearly positive, return only those; do not add negative articles just to fill a top 10 list.\n\n" +
 		"Follow these instructions exactly:\n\n" +
@@ -23,59 +282,41 @@ func RankArticlesWithChatGPT(ctx context.Context, client *openai.Client, article
 		"2. If there are many articles focused on self growth, self improvement, or positive thinking (self-help topics), include no more than 3 of those.\n" +
 		"3. For each article, assign a suitable category from the following: business, entertainment, general, health, science, sports, technology, finance, world, arts, lifestyle.\n" +
 		"4. Ensure that the final output includes only articles that are clearly positive. If fewer than 10 articles are clearly positive, return only those.\n" +
-		"5. Return only a JSON array (with as many elements as are clearly positive) without any additional text. " +
-		"Each JSON object must have the following fields: `rank` (an integer from 1 to N), `title`, `url`, and `category`.\n\n" +
-		"Return only the JSON without any additional text.\n\nArticles:\n"
+		"5. Return the ranking in the `articles` array (with as many elements as are clearly positive). " +
+		"Each element has `rank` (an integer from 1 to N), `title`, `url` (copied exactly from the list below), `category`, " +
+		"`score` (an integer from 0 to 100 rating how positive the article is), `confidence` (a number from 0 to 1 for how sure you are of the score), " +
+		"`reason` (one sentence explaining why the article was chosen), and `flags` (any of \"commerce\", \"self-help\", \"politics\" that apply, or an empty list).\n\n" +
+		"Articles:\n"
 	for i, art := range articles {
 		prompt += fmt.Sprintf("%d. Title: %s\nURL: %s\nExcerpt: %s\n\n", i+1, art.Title, art.URL, art.Excerpt)
 	}
-	req := openai.ChatCompletionRequest{
-		Model: "gpt-4",
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    "system",
-				Content: "You are an expert sentiment analyst who curates news to inspire global positivity.",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-	}
-	resp, err := client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	resultText := strings.TrimSpace(resp.Choices[0].Message.Content)
-	resultText = strings.TrimPrefix(resultText, "```")
-	resultText = strings.TrimSuffix(resultText, "```")
-	resultText = strings.TrimSpace(resultText)
-	start := strings.Index(resultText, "[")
-	end := strings.LastIndex(resultText, "]")
-	if start >= 0 && end >= 0 && end > start {
-		resultText = resultText[start : end+1]
-	}
-	fmt.Println("Cleaned ChatGPT Output:")
-	fmt.Println(resultText)
-	var ranked []RankedArticle
-	if err := json.Unmarshal([]byte(resultText), &ranked); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON ranking: %v\nRaw output: %s", err, resultText)
-	}
-	return ranked, nil
+	return prompt
 }
 
-// selectTopArticles selects up to 10 top articles from the ranked articles.
-func SelectTopArticles(rankedArticles []RankedArticle, validArticles []ArticleWithContent) []ArticleWithContent {
+// DefaultMinScore is the lowest positivity score SelectTopArticles accepts by default.
+const DefaultMinScore = 50
+
+// selectTopArticles selects up to limit top articles scoring at least minScore, copying the ranking fields onto each.
+func SelectTopArticles(rankedArticles []RankedArticle, validArticles []ArticleWithContent, minScore, limit int) []ArticleWithContent {
 	articleMap := make(map[string]ArticleWithContent)
 	for _, art := range validArticles {
-		articleMap[art.URL] = art
+		articleMap[CanonicalURL(art.URL)] = art
 	}
 	var topArticles []ArticleWithContent
 	for _, ra := range rankedArticles {
-		if art, ok := articleMap[ra.URL]; ok {
+		if ra.Score < minScore {
+			fmt.Printf("Dropping '%s': score %d is below the minimum of %d\n", ra.Title, ra.Score, minScore)
+			continue
+		}
+		if art, ok := articleMap[CanonicalURL(ra.URL)]; ok {
+			art.Category = ra.Category
+			art.Score = ra.Score
+			art.Confidence = ra.Confidence
+			art.Reason = ra.Reason
+			art.Flags = ra.Flags
 			topArticles = append(topArticles, art)
 		}
-		if len(topArticles) >= 10 {
+		if len(topArticles) >= limit {
 			break
 		}
 	}
@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseRanking(t *testing.T) {
+	articles := []ArticleWithContent{
+		{URL: "https://example.com/a"},
+		{URL: "https://example.com/b"},
+	}
+	raw := `{"articles": [
+		{"rank": 1, "title": "A", "url": "https://example.com/a", "category": "science", "score": 90, "confidence": 0.8, "reason": "r", "flags": ["commerce", "commerce", "politics"]},
+		{"rank": 2, "title": "B", "url": "https://example.com/b", "category": "health", "score": 70, "confidence": 0.5, "reason": "r", "flags": []}
+	]}`
+	ranked, err := parseRanking(raw, articles)
+	if err != nil {
+		t.Fatalf("parseRanking: %v", err)
+	}
+	if want := []string{FlagCommerce, FlagPolitics}; !reflect.DeepEqual(ranked[0].Flags, want) {
+		t.Errorf("flags = %v, want %v", ranked[0].Flags, want)
+	}
+}
+
+func TestParseRankingRejectsInvalid(t *testing.T) {
+	articles := []ArticleWithContent{{URL: "https://example.com/a"}}
+	tests := map[string]string{
+		"unknown url":      `{"articles": [{"rank": 1, "title": "X", "url": "https://example.com/x", "category": "science", "score": 90, "confidence": 0.8, "reason": "r", "flags": []}]}`,
+		"bad rank":         `{"articles": [{"rank": 2, "title": "A", "url": "https://example.com/a", "category": "science", "score": 90, "confidence": 0.8, "reason": "r", "flags": []}]}`,
+		"unknown flag":     `{"articles": [{"rank": 1, "title": "A", "url": "https://example.com/a", "category": "science", "score": 90, "confidence": 0.8, "reason": "r", "flags": ["uplifting", "uplifting"]}]}`,
+		"score over range": `{"articles": [{"rank": 1, "title": "A", "url": "https://example.com/a", "category": "science", "score": 101, "confidence": 0.8, "reason": "r", "flags": []}]}`,
+		"not json":         `{"articles": [`,
+	}
+	for name, raw := range tests {
+		_, err := parseRanking(raw, articles)
+		var verr *RankingValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: err = %v, want a RankingValidationError", name, err)
+		}
+	}
+}
+
+// fakeChatServer answers every chat completion with content and records the request bodies.
+func fakeChatServer(t *testing.T, content string) (*httptest.Server, *[]map[string]interface{}) {
+	t.Helper()
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"index": 0, "message": map[string]string{"role": "assistant", "content": content}},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestRankWithFallbackUsesLexiconAfterInvalidRanking(t *testing.T) {
+	srv, requests := fakeChatServer(t, `{"articles": [{"rank": 1, "title": "X", "url": "https://example.com/made-up"}]}`)
+	llm := NewOpenAICompatibleRanker(srv.URL+"/v1", "", "test-model", nil)
+	llm.MaxRepairs = 1
+	articles := []ArticleWithContent{
+		{URL: "https://example.com/a", Title: "Volunteers rescue a reef", Excerpt: "An inspiring, hopeful success for the community."},
+	}
+
+	if _, err := llm.Rank(context.Background(), articles); !errors.As(err, new(*RankingValidationError)) {
+		t.Fatalf("LLM ranker err = %v, want a RankingValidationError", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("LLM ranker sent %d requests, want 1 plus 1 repair", len(*requests))
+	}
+
+	ranked, name, err := RankWithFallback(context.Background(), []Ranker{llm, NewLexiconRanker()}, articles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "lexicon" || len(ranked) != 1 || ranked[0].URL != "https://example.com/a" {
+		t.Errorf("RankWithFallback = %v from %q, want the lexicon ranking", ranked, name)
+	}
+
+	if _, _, err := RankWithFallback(context.Background(), []Ranker{llm}, articles); !errors.As(err, new(*RankingValidationError)) {
+		t.Errorf("RankWithFallback with only the LLM = %v, want it to wrap the RankingValidationError", err)
+	}
+}
+
+func TestOpenAIRankerSendsExplicitZeroTemperature(t *testing.T) {
+	articles := []ArticleWithContent{{URL: "https://example.com/a", Title: "A"}}
+	ranking := `{"articles": [{"rank": 1, "title": "A", "url": "https://example.com/a", "category": "science", "score": 90, "confidence": 0.8, "reason": "r", "flags": []}]}`
+	zero, warm, tiny := float32(0), float32(0.7), float32(math.SmallestNonzeroFloat32)
+	tests := []struct {
+		name        string
+		temperature *float32
+		want        *float32 // nil means the field is left out
+	}{
+		{"server default", nil, nil},
+		{"explicit zero", &zero, &tiny}, // the smallest float32 stands in for 0, which the client omits
+		{"warm", &warm, &warm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := fakeChatServer(t, ranking)
+			r := NewOpenAICompatibleRanker(srv.URL+"/v1", "", "test-model", tt.temperature)
+			if _, err := r.Rank(context.Background(), articles); err != nil {
+				t.Fatal(err)
+			}
+			got, sent := (*requests)[0]["temperature"].(float64)
+			switch {
+			case tt.want == nil && sent:
+				t.Errorf("temperature = %v, want it left out", got)
+			case tt.want != nil && (!sent || float32(got) != *tt.want):
+				t.Errorf("temperature = %v (sent %v), want %v", got, sent, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// runlock.go
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultRunLockLease is how long a run without a deadline (the CLI) holds the daily lock before
+// another invocation may assume it died and take over.
+const DefaultRunLockLease = 15 * time.Minute
+
+// runLockLeaseSlack is added to the time an invocation has left to get its lease. It is shorter
+// than Lambda's first async retry delay, so a retry after a timeout finds the lease expired.
+const runLockLeaseSlack = 30 * time.Second
+
+// runLockReleaseReserve is held back from the invocation's deadline so the lock can still be
+// released when generation runs out of time.
+const runLockReleaseReserve = 5 * time.Second
+
+// runLockLease returns the lease for a run bounded by ctx's deadline, or fallback if it has none.
+func runLockLease(ctx context.Context, fallback time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return fallback
+	}
+	return time.Until(deadline) + runLockLeaseSlack
+}
+
+// RunLock guards content generation for one UTC day so that duplicate EventBridge deliveries
+// and Lambda retries do not send the digest twice.
+//
+// A day's lock can be taken when it does not exist yet, when its run failed, or when its lease
+// expired without a result. In the last two cases the new invocation resumes the earlier run
+// and keeps its run ID. A lock whose run was sent is only replaced when forced, and a forced
+// rerun gets a new run ID. A running lock inside its lease is never taken, forced or not.
+type RunLock struct {
+	Date       string    `json:"date"` // YYYY-MM-DD
+	RunID      string    `json:"runId"`
+	Status     string    `json:"status"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// Resumed is set when the lock was taken over from a failed or expired run.
+	Resumed bool `json:"-"`
+}
+
+// RunLockError is returned when the day's lock is held by a completed or running run.
+type RunLockError struct {
+	Date   string
+	RunID  string
+	Status string
+}
+
+func (e *RunLockError) Error() string {
+	if e.RunID == "" {
+		return fmt.Sprintf("digest for %s is being generated by another invocation", e.Date)
+	}
+	if e.Status == RunStatusSent {
+		return fmt.Sprintf("digest for %s was already sent by run %s", e.Date, e.RunID)
+	}
+	return fmt.Sprintf("digest for %s is being generated by run %s", e.Date, e.RunID)
+}
+
+// nextRunLock decides whether runID may take the lock for day given the current lock (nil if
+// there is none), and returns the lock to write.
+func nextRunLock(current *RunLock, day time.Time, runID string, now time.Time, lease time.Duration, force bool) (RunLock, error) {
+	now = now.UTC()
+	next := RunLock{
+		Date:       day.UTC().Format("2006-01-02"),
+		RunID:      runID,
+		Status:     RunStatusRunning,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(lease),
+	}
+	if current == nil {
+		return next, nil
+	}
+	switch {
+	case current.Status == RunStatusSent && force:
+		fmt.Printf("Forcing a new run for %s; run %s already sent\n", next.Date, current.RunID)
+	case current.Status == RunStatusSent:
+		return RunLock{}, &RunLockError{Date: current.Date, RunID: current.RunID, Status: current.Status}
+	case current.Status == RunStatusFailed || !now.Before(current.ExpiresAt):
+		fmt.Printf("Resuming run %s for %s (status %s)\n", current.RunID, next.Date, current.Status)
+		next.RunID = current.RunID
+		next.Resumed = true
+	default:
+		return RunLock{}, &RunLockError{Date: current.Date, RunID: current.RunID, Status: current.Status}
+	}
+	return next, nil
+}
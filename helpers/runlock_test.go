@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunLockLease(t *testing.T) {
+	if got := runLockLease(context.Background(), 15*time.Minute); got != 15*time.Minute {
+		t.Errorf("without a deadline: lease = %v, want the fallback", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	got := runLockLease(ctx, 15*time.Minute)
+	if got > 5*time.Minute+runLockLeaseSlack || got < 5*time.Minute+runLockLeaseSlack-time.Second {
+		t.Errorf("with a 5m deadline: lease = %v, want about %v", got, 5*time.Minute+runLockLeaseSlack)
+	}
+}
+
+func TestNextRunLock(t *testing.T) {
+	now := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	lease := 10 * time.Minute
+	lock := func(status string, expiresIn time.Duration) *RunLock {
+		return &RunLock{Date: "2026-10-17", RunID: "run-old", Status: status, AcquiredAt: now.Add(-time.Hour), ExpiresAt: now.Add(expiresIn)}
+	}
+	tests := []struct {
+		name        string
+		current     *RunLock
+		force       bool
+		wantRunID   string // "" means a RunLockError
+		wantResumed bool
+	}{
+		{"no lock", nil, false, "run-new", false},
+		{"sent without force", lock(RunStatusSent, -time.Hour), false, "", false},
+		{"sent with force", lock(RunStatusSent, -time.Hour), true, "run-new", false},
+		{"sent with force inside its lease", lock(RunStatusSent, time.Hour), true, "run-new", false},
+		{"failed resumes and keeps the run ID", lock(RunStatusFailed, time.Hour), false, "run-old", true},
+		{"failed with force still resumes", lock(RunStatusFailed, time.Hour), true, "run-old", true},
+		{"expired lease resumes", lock(RunStatusRunning, -time.Second), false, "run-old", true},
+		{"lease ending now resumes", lock(RunStatusRunning, 0), false, "run-old", true},
+		{"live lease held by another run", lock(RunStatusRunning, time.Minute), false, "", false},
+		{"live lease is not forced", lock(RunStatusRunning, time.Minute), true, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextRunLock(tt.current, now, "run-new", now, lease, tt.force)
+			if tt.wantRunID == "" {
+				var lockErr *RunLockError
+				if !errors.As(err, &lockErr) {
+					t.Fatalf("err = %v, want a RunLockError", err)
+				}
+				if lockErr.RunID != tt.current.RunID || lockErr.Status != tt.current.Status {
+					t.Errorf("RunLockError = %+v, want run %s with status %s", lockErr, tt.current.RunID, tt.current.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			want := RunLock{Date: "2026-10-17", RunID: tt.wantRunID, Status: RunStatusRunning, AcquiredAt: now, ExpiresAt: now.Add(lease), Resumed: tt.wantResumed}
+			if got != want {
+				t.Errorf("lock = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
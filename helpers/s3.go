@@ -12,18 +12,14 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-const (
-	bucketName = "pk-positive-news" // Change this to your S3 bucket name
-	objectKey  = "latest_news.json" // File that stores the latest 10 articles
-	region     = "us-east-2"        // Change to your AWS region
-	indexKey   = "index.html"       // File to update
-)
-
 // UploadJSONToS3 uploads JSON data to an S3 bucket
-func UploadJSONToS3(ctx context.Context, data interface{}) error {
-	cfg, _ := LoadAWSConfigWithRegion(ctx, region)
+func UploadJSONToS3(ctx context.Context, cfg Config, data interface{}) error {
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
+	if err != nil {
+		return err
+	}
 
-	s3Client := s3.NewFromConfig(cfg)
+	s3Client := s3.NewFromConfig(awsCfg)
 
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
@@ -31,8 +27,8 @@ func UploadJSONToS3(ctx context.Context, data interface{}) error {
 	}
 
 	input := &s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(objectKey),
+		Bucket:      aws.String(cfg.BucketName),
+		Key:         aws.String(cfg.LatestNewsKey),
 		Body:        strings.NewReader(string(jsonData)),
 		ContentType: aws.String("application/json"),
 	}
@@ -42,21 +38,24 @@ func UploadJSONToS3(ctx context.Context, data interface{}) error {
 		return fmt.Errorf("failed to upload JSON to S3: %w", err)
 	}
 
-	fmt.Println("Successfully uploaded latest news to S3:", objectKey)
+	fmt.Println("Successfully uploaded latest news to S3:", cfg.LatestNewsKey)
 	return nil
 }
 
 // GeneratePreSignedURL creates a temporary S3 URL for latest_news.json
-func GeneratePreSignedURL(ctx context.Context) (string, error) {
-	cfg, _ := LoadAWSConfigWithRegion(ctx, region)
+func GeneratePreSignedURL(ctx context.Context, cfg Config) (string, error) {
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
+	if err != nil {
+		return "", err
+	}
 
-	s3Client := s3.NewFromConfig(cfg)
+	s3Client := s3.NewFromConfig(awsCfg)
 	psClient := s3.NewPresignClient(s3Client)
 
 	// Create a pre-signed URL that expires in 24 hours
 	req, err := psClient.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
+		Bucket: aws.String(cfg.BucketName),
+		Key:    aws.String(cfg.LatestNewsKey),
 	}, s3.WithPresignExpires(24*time.Hour))
 	if err != nil {
 		return "", fmt.Errorf("failed to generate pre-signed URL: %w", err)
@@ -66,36 +65,22 @@ func GeneratePreSignedURL(ctx context.Context) (string, error) {
 }
 
 // UpdateIndexHTML replaces the pre-signed URL inside index.html and uploads the new version to S3
-func UpdateIndexHTML(ctx context.Context, preSignedURL string) error {
-	cfg, _ := LoadAWSConfigWithRegion(ctx, region)
-	s3Client := s3.NewFromConfig(cfg)
-
-	// Fetch the existing index.html from S3
-	getInput := &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(indexKey),
-	}
-
-	resp, err := s3Client.GetObject(ctx, getInput)
+func UpdateIndexHTML(ctx context.Context, cfg Config, preSignedURL string) error {
+	updatedHTML, err := RenderIndexHTML(ctx, cfg, preSignedURL)
 	if err != nil {
-		return fmt.Errorf("failed to fetch existing index.html: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	// Read the file content
-	bodyBytes, err := io.ReadAll(resp.Body)
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
 	if err != nil {
-		return fmt.Errorf("failed to read index.html content: %w", err)
+		return err
 	}
-	htmlContent := string(bodyBytes) // Convert bytes to string
-
-	// Replace the old pre-signed URL with the new one
-	updatedHTML := strings.Replace(htmlContent, `"https://your-s3-bucket.s3.amazonaws.com/latest_news.json?...signed-url-params"`, `"`+preSignedURL+`"`, 1)
+	s3Client := s3.NewFromConfig(awsCfg)
 
 	// Upload the modified index.html back to S3
 	putInput := &s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(indexKey),
+		Bucket:      aws.String(cfg.BucketName),
+		Key:         aws.String(cfg.IndexKey),
 		Body:        strings.NewReader(updatedHTML),
 		ContentType: aws.String("text/html"),
 	}
@@ -108,3 +93,34 @@ func UpdateIndexHTML(ctx context.Context, preSignedURL string) error {
 	fmt.Println("Successfully updated and re-uploaded index.html to S3.")
 	return nil
 }
+
+// RenderIndexHTML fetches the live index.html and returns it with the pre-signed URL substituted, without uploading it.
+func RenderIndexHTML(ctx context.Context, cfg Config, preSignedURL string) (string, error) {
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
+	if err != nil {
+		return "", err
+	}
+	s3Client := s3.NewFromConfig(awsCfg)
+
+	// Fetch the existing index.html from S3
+	getInput := &s3.GetObjectInput{
+		Bucket: aws.String(cfg.BucketName),
+		Key:    aws.String(cfg.IndexKey),
+	}
+
+	resp, err := s3Client.GetObject(ctx, getInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch existing index.html: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read the file content
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read index.html content: %w", err)
+	}
+	htmlContent := string(bodyBytes) // Convert bytes to string
+
+	// Replace the old pre-signed URL with the new one
+	return strings.Replace(htmlContent, `"https://your-s3-bucket.s3.amazonaws.com/latest_news.json?...signed-url-params"`, `"`+preSignedURL+`"`, 1), nil
+}
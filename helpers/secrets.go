@@ -4,26 +4,55 @@ package helpers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	sm "github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 )
 
-// getSecrets retrieves NEWS_API_KEY and OPENAI_API_KEY from AWS Secrets Manager.
-func GetSecrets(ctx context.Context) (newsAPIKey, openaiAPIKey string, err error) {
-	cfg, _ := LoadAWSConfig(ctx)
-	smClient := sm.NewFromConfig(cfg)
-	secretName := "positiveNews_openai_newsapi_keys" // Hardcoded secret name.
+// getSecrets retrieves NEWS_API_KEY and OPENAI_API_KEY from the configured AWS Secrets Manager secret.
+func GetSecrets(ctx context.Context, cfg Config) (newsAPIKey, openaiAPIKey string, err error) {
+	secretMap, err := readSecret(ctx, cfg)
+	if err != nil {
+		return "", "", err
+	}
+	return secretMap["NEWS_API_KEY"], secretMap["OPENAI_API_KEY"], nil
+}
+
+// ConfirmationSecret returns the secret that signs confirmation and unsubscribe links:
+// cfg.ConfirmSecret, or CONFIRM_SECRET from the Secrets Manager secret.
+func ConfirmationSecret(ctx context.Context, cfg Config) (string, error) {
+	secret := cfg.ConfirmSecret
+	if secret == "" {
+		secretMap, err := readSecret(ctx, cfg)
+		if err != nil {
+			return "", err
+		}
+		secret = secretMap["CONFIRM_SECRET"]
+	}
+	if len(secret) < minTokenSecretLen {
+		return "", fmt.Errorf("the confirmation secret must be at least %d characters", minTokenSecretLen)
+	}
+	return secret, nil
+}
+
+// readSecret reads the configured secret as a JSON object of strings.
+func readSecret(ctx context.Context, cfg Config) (map[string]string, error) {
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	smClient := sm.NewFromConfig(awsCfg)
 	input := &sm.GetSecretValueInput{
-		SecretId: aws.String(secretName),
+		SecretId: aws.String(cfg.SecretName),
 	}
 	result, err := smClient.GetSecretValue(ctx, input)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	var secretMap map[string]string
 	if err := json.Unmarshal([]byte(*result.SecretString), &secretMap); err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return secretMap["NEWS_API_KEY"], secretMap["OPENAI_API_KEY"], nil
+	return secretMap, nil
 }
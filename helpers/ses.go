@@ -0,0 +1,125 @@
+// ses.go
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sesTypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+// sesSendConcurrency bounds the SendEmail calls in flight; SES throttling is retried by the SDK.
+const sesSendConcurrency = 4
+
+// SESMailer sends every subscriber their own multipart HTML and plain-text message through
+// Amazon SES, with a signed personal unsubscribe link when cfg.UnsubscribeURL is set.
+type SESMailer struct {
+	Client   *sesv2.Client
+	Config   Config
+	Renderer *EmailRenderer
+	// Recipients returns the subscribers to send to (see recipients).
+	Recipients func(ctx context.Context) ([]Subscriber, error)
+}
+
+// NewSESMailer returns an SESMailer for the configured region and sender.
+func NewSESMailer(ctx context.Context, cfg Config, renderer *EmailRenderer) (*SESMailer, error) {
+	awsCfg, err := LoadAWSConfigWithRegion(ctx, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	return &SESMailer{
+		Client:   sesv2.NewFromConfig(awsCfg),
+		Config:   cfg,
+		Renderer: renderer,
+		Recipients: func(ctx context.Context) ([]Subscriber, error) {
+			return recipients(ctx, cfg)
+		},
+	}, nil
+}
+
+// Name returns "ses".
+func (m *SESMailer) Name() string {
+	return MailerSES
+}
+
+// SendDigest renders and sends one message per recipient not skipped. Deliveries are reported in
+// recipient order.
+func (m *SESMailer) SendDigest(ctx context.Context, digest Digest, skip func(recipient string) bool) (DeliveryReport, error) {
+	report := DeliveryReport{Mailer: m.Name()}
+	recipients, err := m.Recipients(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return report, fmt.Errorf("no recipients to send to")
+	}
+	if recipients = pending(recipients, skip); len(recipients) == 0 {
+		fmt.Println("Every recipient already has the digest")
+		return report, nil
+	}
+
+	secret, err := unsubscribeSecret(ctx, m.Config)
+	if err != nil {
+		return report, err
+	}
+
+	report.Deliveries = make([]Delivery, len(recipients))
+	sem := make(chan struct{}, sesSendConcurrency)
+	var wg sync.WaitGroup
+	for i, to := range recipients {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, to Subscriber) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report.Deliveries[i] = m.sendTo(ctx, secret, digest, to)
+		}(i, to)
+	}
+	wg.Wait()
+
+	failed := report.Failed()
+	fmt.Printf("SES delivered %d of %d messages\n", len(recipients)-len(failed), len(recipients))
+	if len(failed) == len(recipients) {
+		return report, fmt.Errorf("all %d SES sends failed, last error: %s", len(failed), failed[len(failed)-1].Error)
+	}
+	if err := ctx.Err(); err != nil {
+		return report, fmt.Errorf("SES sending stopped with %d of %d recipients unsent: %w", len(failed), len(recipients), err)
+	}
+	return report, nil
+}
+
+// sendTo renders the digest for one recipient and sends it as a raw MIME message.
+func (m *SESMailer) sendTo(ctx context.Context, secret string, digest Digest, sub Subscriber) Delivery {
+	to := sub.Email
+	delivery := Delivery{Recipient: to}
+	digest = personalize(m.Config, secret, digest, sub)
+	email, err := m.Renderer.Render(digest)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	delivery.MessageID, err = m.SendMessage(ctx, to, email, unsubscribeHeaders(digest.UnsubscribeURL))
+	if err != nil {
+		fmt.Printf("Error sending to %s via SES: %v\n", to, err)
+		delivery.Error = err.Error()
+	}
+	return delivery
+}
+
+// SendMessage sends one rendered message as a raw MIME message and returns the SES message ID.
+func (m *SESMailer) SendMessage(ctx context.Context, to string, email RenderedEmail, headers map[string]string) (string, error) {
+	out, err := m.Client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(m.Config.EmailFrom),
+		Destination:      &sesTypes.Destination{ToAddresses: []string{to}},
+		Content: &sesTypes.EmailContent{
+			Raw: &sesTypes.RawMessage{Data: BuildMIMEMessage(m.Config.EmailFrom, to, email, headers)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.MessageId), nil
+}
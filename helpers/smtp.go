@@ -0,0 +1,288 @@
+// smtp.go
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP transport security modes.
+const (
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "implicit"
+	SMTPTLSNone     = "none"
+)
+
+// SMTP authentication mechanisms. Authentication is skipped when no username is configured.
+const (
+	SMTPAuthPlain = "plain"
+	SMTPAuthLogin = "login"
+)
+
+// smtpTimeout bounds dialing and each message sent over the connection.
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer sends every recipient their own multipart message over a single SMTP connection,
+// reconnecting if the server drops it part way through the batch.
+type SMTPMailer struct {
+	Config   Config
+	Renderer *EmailRenderer
+	// Recipients returns the subscribers to send to (see recipients).
+	Recipients func(ctx context.Context) ([]Subscriber, error)
+}
+
+// NewSMTPMailer returns an SMTPMailer for the configured server.
+func NewSMTPMailer(cfg Config, renderer *EmailRenderer) *SMTPMailer {
+	return &SMTPMailer{
+		Config:   cfg,
+		Renderer: renderer,
+		Recipients: func(ctx context.Context) ([]Subscriber, error) {
+			return recipients(ctx, cfg)
+		},
+	}
+}
+
+// Name returns "smtp".
+func (m *SMTPMailer) Name() string {
+	return MailerSMTP
+}
+
+// SendDigest renders and sends one message per recipient not skipped. The Message-ID header is
+// recorded as the delivery's message ID.
+func (m *SMTPMailer) SendDigest(ctx context.Context, digest Digest, skip func(recipient string) bool) (DeliveryReport, error) {
+	report := DeliveryReport{Mailer: m.Name()}
+	recipients, err := m.Recipients(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return report, fmt.Errorf("no recipients to send to")
+	}
+	if recipients = pending(recipients, skip); len(recipients) == 0 {
+		fmt.Println("Every recipient already has the digest")
+		return report, nil
+	}
+	from, err := mail.ParseAddress(m.Config.EmailFrom)
+	if err != nil {
+		return report, fmt.Errorf("invalid sender %q: %w", m.Config.EmailFrom, err)
+	}
+	secret, err := unsubscribeSecret(ctx, m.Config)
+	if err != nil {
+		return report, err
+	}
+
+	var conn *smtpConn
+	defer func() {
+		if conn != nil {
+			conn.Quit()
+		}
+	}()
+	for i, to := range recipients {
+		delivery := Delivery{Recipient: to.Email}
+		if err = ctx.Err(); err == nil && conn == nil {
+			conn, err = m.dial(ctx)
+		}
+		if err != nil {
+			// Cancelled, or without a connection: the rest of the batch cannot go out either.
+			for _, rest := range recipients[i:] {
+				report.Deliveries = append(report.Deliveries, Delivery{Recipient: rest.Email, Error: err.Error()})
+			}
+			break
+		}
+		delivery.MessageID, err = m.sendTo(conn, from, secret, digest, to)
+		if err != nil {
+			fmt.Printf("Error sending to %s via SMTP: %v\n", to.Email, err)
+			delivery.Error = err.Error()
+			// Start the next recipient on a clean transaction, or a new connection if this one is gone.
+			if resetErr := conn.Reset(); resetErr != nil {
+				conn.Close()
+				conn = nil
+			}
+		}
+		report.Deliveries = append(report.Deliveries, delivery)
+	}
+
+	failed := report.Failed()
+	fmt.Printf("SMTP delivered %d of %d messages\n", len(recipients)-len(failed), len(recipients))
+	if len(failed) == len(recipients) {
+		return report, fmt.Errorf("all %d SMTP sends failed, last error: %s", len(failed), failed[len(failed)-1].Error)
+	}
+	if err := ctx.Err(); err != nil {
+		// The run stops here, and its retry sends to whoever is left (see generateDigest).
+		return report, fmt.Errorf("SMTP sending stopped with %d of %d recipients unsent: %w", len(failed), len(recipients), err)
+	}
+	return report, nil
+}
+
+// sendTo renders the digest for one recipient and sends it as one SMTP transaction.
+func (m *SMTPMailer) sendTo(conn *smtpConn, from *mail.Address, secret string, digest Digest, sub Subscriber) (string, error) {
+	to := sub.Email
+	digest = personalize(m.Config, secret, digest, sub)
+	email, err := m.Renderer.Render(digest)
+	if err != nil {
+		return "", err
+	}
+	return transmit(conn, from, to, email, unsubscribeHeaders(digest.UnsubscribeURL))
+}
+
+// SendMessage sends one rendered message over a connection of its own and returns its Message-ID.
+func (m *SMTPMailer) SendMessage(ctx context.Context, to string, email RenderedEmail, headers map[string]string) (string, error) {
+	from, err := mail.ParseAddress(m.Config.EmailFrom)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender %q: %w", m.Config.EmailFrom, err)
+	}
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Quit()
+	return transmit(conn, from, to, email, headers)
+}
+
+// transmit sends the message as one SMTP transaction with a new Message-ID, which it returns.
+func transmit(conn *smtpConn, from *mail.Address, to string, email RenderedEmail, extra map[string]string) (string, error) {
+	messageID := newMessageID(from.Address)
+	headers := map[string]string{"Message-ID": messageID}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	msg := BuildMIMEMessage(from.String(), to, email, headers)
+
+	conn.raw.SetDeadline(time.Now().Add(smtpTimeout))
+	if err := conn.Mail(from.Address); err != nil {
+		return "", err
+	}
+	if err := conn.Rcpt(to); err != nil {
+		return "", err
+	}
+	w, err := conn.Data()
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+// smtpConn is an SMTP client together with its network connection, for deadlines.
+type smtpConn struct {
+	*smtp.Client
+	raw net.Conn
+}
+
+// dial connects to the server, negotiates TLS and authenticates as configured.
+func (m *SMTPMailer) dial(ctx context.Context) (*smtpConn, error) {
+	cfg := m.Config
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: cfg.SMTPHost}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var raw net.Conn
+	var err error
+	if cfg.SMTPTLS == SMTPTLSImplicit {
+		raw, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		raw, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	raw.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(raw, cfg.SMTPHost)
+	if err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("failed to start SMTP session with %s: %w", addr, err)
+	}
+	conn := &smtpConn{Client: client, raw: raw}
+	if err := m.negotiate(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (m *SMTPMailer) negotiate(conn *smtpConn) error {
+	cfg := m.Config
+	if cfg.SMTPTLS == SMTPTLSStartTLS {
+		if ok, _ := conn.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", cfg.SMTPHost)
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: cfg.SMTPHost}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if cfg.SMTPUsername == "" {
+		return nil
+	}
+	var auth smtp.Auth
+	switch cfg.SMTPAuth {
+	case SMTPAuthLogin:
+		auth = &loginAuth{username: cfg.SMTPUsername, password: cfg.SMTPPassword, host: cfg.SMTPHost}
+	default:
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	if err := conn.Auth(auth); err != nil {
+		return fmt.Errorf("SMTP authentication failed: %w", err)
+	}
+	return nil
+}
+
+// loginAuth implements the non-standard but widely used AUTH LOGIN mechanism. Like
+// smtp.PlainAuth it refuses to send credentials over an unencrypted connection except to localhost.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected AUTH LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// newMessageID returns a unique RFC 5322 Message-ID in the sender's domain.
+func newMessageID(from string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok && d != "" {
+		domain = d
+	}
+	var id [12]byte
+	rand.Read(id[:])
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().Unix(), hex.EncodeToString(id[:]), domain)
+}
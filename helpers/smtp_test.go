@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts every message on one connection, calling onMessage before it
+// acknowledges each one.
+func fakeSMTPServer(t *testing.T, onMessage func()) (host string, port int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					if line, err = r.ReadString('\n'); err != nil || line == ".\r\n" {
+						break
+					}
+				}
+				onMessage()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestSMTPMailerStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	host, port := fakeSMTPServer(t, cancel)
+
+	cfg := DefaultConfig()
+	cfg.Mailer = MailerSMTP
+	cfg.EmailFrom = "news@example.com"
+	cfg.SMTPHost = host
+	cfg.SMTPPort = port
+	cfg.SMTPTLS = SMTPTLSNone
+	renderer, err := NewEmailRenderer("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailer := NewSMTPMailer(cfg, renderer)
+	mailer.Recipients = func(ctx context.Context) ([]Subscriber, error) {
+		var subs []Subscriber
+		for i := 0; i < 3; i++ {
+			subs = append(subs, Subscriber{Email: "reader" + strconv.Itoa(i) + "@example.com", Status: SubscriberActive})
+		}
+		return subs, nil
+	}
+
+	report, err := mailer.SendDigest(ctx, NewDigest(cfg, nil), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendDigest error = %v, want it to wrap context.Canceled", err)
+	}
+	if len(report.Deliveries) != 3 {
+		t.Fatalf("got %d deliveries, want 3", len(report.Deliveries))
+	}
+	if report.Deliveries[0].Error != "" || report.Deliveries[0].MessageID == "" {
+		t.Errorf("first delivery = %+v, want it sent", report.Deliveries[0])
+	}
+	for _, d := range report.Deliveries[1:] {
+		if d.Error != context.Canceled.Error() {
+			t.Errorf("delivery to %s: error %q, want %q", d.Recipient, d.Error, context.Canceled)
+		}
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Fatal("server never cancelled the context")
+	}
+}
@@ -17,10 +17,10 @@ func NewSNSClient(ctx context.Context, region string) (*sns.Client, error) {
 	return sns.NewFromConfig(cfg), nil
 }
 
-// SubscribeUser subscribes the given email to the SNS topic.
+// SubscribeUser subscribes the given email to the configured SNS topic.
 // It uses the SNS client to call the Subscribe API.
-func SubscribeUser(ctx context.Context, topicARN string, email string) error {
-	client, err := NewSNSClient(ctx, "us-east-2")
+func SubscribeUser(ctx context.Context, cfg Config, email string) error {
+	client, err := NewSNSClient(ctx, cfg.Region)
 	if err != nil {
 		return fmt.Errorf("failed to create SNS client: %w", err)
 	}
@@ -28,7 +28,7 @@ func SubscribeUser(ctx context.Context, topicARN string, email string) error {
 	_, err = client.Subscribe(ctx, &sns.SubscribeInput{
 		Protocol: aws.String("email"),
 		Endpoint: aws.String(email),
-		TopicArn: aws.String(topicARN),
+		TopicArn: aws.String(cfg.SNSTopicARN),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to subscribe %s: %w", email, err)
@@ -36,17 +36,17 @@ func SubscribeUser(ctx context.Context, topicARN string, email string) error {
 	return nil
 }
 
-// UnsubscribeUser removes an email subscription from the SNS topic.
+// UnsubscribeUser removes an email subscription from the configured SNS topic.
 // It returns a message and an error.
-func UnsubscribeUser(ctx context.Context, topicARN string, email string) (string, error) {
-	client, err := NewSNSClient(ctx, "us-east-2")
+func UnsubscribeUser(ctx context.Context, cfg Config, email string) (string, error) {
+	client, err := NewSNSClient(ctx, cfg.Region)
 	if err != nil {
 		return "", fmt.Errorf("failed to create SNS client: %w", err)
 	}
 
 	// List subscriptions for the topic.
 	input := &sns.ListSubscriptionsByTopicInput{
-		TopicArn: aws.String(topicARN),
+		TopicArn: aws.String(cfg.SNSTopicARN),
 	}
 
 	result, err := client.ListSubscriptionsByTopic(ctx, input)
@@ -83,23 +83,60 @@ func UnsubscribeUser(ctx context.Context, topicARN string, email string) (string
 	return fmt.Sprintf("Successfully unsubscribed %s", email), nil
 }
 
-// SendEmailViaSNS sends a plain text email via SNS with the given subject and message.
-func SendEmail(ctx context.Context, topicARN, subject, message string) error {
-	client, err := NewSNSClient(ctx, "us-east-2")
+// TopicSubscriber is an email endpoint subscribed to the SNS topic.
+// SubscriptionARN is "PendingConfirmation" until the subscriber confirms.
+type TopicSubscriber struct {
+	Email           string
+	SubscriptionARN string
+}
+
+// ListTopicSubscribers returns every email subscription on the configured SNS topic.
+func ListTopicSubscribers(ctx context.Context, cfg Config) ([]TopicSubscriber, error) {
+	client, err := NewSNSClient(ctx, cfg.Region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SNS client: %w", err)
+	}
+
+	var subscribers []TopicSubscriber
+	paginator := sns.NewListSubscriptionsByTopicPaginator(client, &sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(cfg.SNSTopicARN),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+		for _, sub := range page.Subscriptions {
+			if aws.ToString(sub.Protocol) != "email" {
+				continue
+			}
+			subscribers = append(subscribers, TopicSubscriber{
+				Email:           aws.ToString(sub.Endpoint),
+				SubscriptionARN: aws.ToString(sub.SubscriptionArn),
+			})
+		}
+	}
+	return subscribers, nil
+}
+
+// SendEmailViaSNS sends a plain text email via the configured SNS topic with the given subject and message.
+// It returns the SNS message ID.
+func SendEmail(ctx context.Context, cfg Config, subject, message string) (string, error) {
+	client, err := NewSNSClient(ctx, cfg.Region)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	input := &sns.PublishInput{
-		TopicArn: aws.String(topicARN),
+		TopicArn: aws.String(cfg.SNSTopicARN),
 		Subject:  aws.String(subject),
 		Message:  aws.String(message),
 	}
 
-	_, err = client.Publish(ctx, input)
+	out, err := client.Publish(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to publish SNS message: %w", err)
+		return "", fmt.Errorf("failed to publish SNS message: %w", err)
 	}
 
-	return nil
+	return aws.ToString(out.MessageId), nil
 }
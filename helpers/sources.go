@@ -0,0 +1,125 @@
+// sources.go
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+)
+
+// NewsSource is a provider of candidate articles for a given time window.
+// Pages are 1-based; a source returns an empty slice once it has no more results.
+type NewsSource interface {
+	Name() string
+	FetchPage(ctx context.Context, from, to time.Time, page int) ([]Article, error)
+}
+
+// DefaultNewsQuery is the keyword query sent to search-based sources.
+const DefaultNewsQuery = "inspiring OR heartwarming OR motivational OR encouraging OR breakthrough OR innovation OR success OR 'good news' OR uplifting OR inspiring -crisis -war -tragedy -disaster -shooting"
+
+// NewsAPISource fetches articles from NewsAPI's /v2/everything endpoint.
+type NewsAPISource struct {
+	APIKey   string
+	Query    string
+	PageSize int
+	BaseURL  string
+	Client   *HTTPClient
+}
+
+// NewNewsAPISource returns a NewsAPISource using the configured endpoint, query and page size.
+func NewNewsAPISource(cfg Config, apiKey string) *NewsAPISource {
+	return &NewsAPISource{
+		APIKey:   apiKey,
+		Query:    cfg.NewsQuery,
+		PageSize: cfg.NewsPageSize,
+		BaseURL:  cfg.NewsAPIURL,
+		Client:   cfg.HTTPClient(),
+	}
+}
+
+// Name identifies the source in logs.
+func (s *NewsAPISource) Name() string {
+	return "newsapi"
+}
+
+// FetchPage retrieves one page of articles published between from and to.
+func (s *NewsAPISource) FetchPage(ctx context.Context, from, to time.Time, page int) ([]Article, error) {
+	requestURL := fmt.Sprintf("%s?q=%s&from=%s&to=%s&sortBy=relevancy&pageSize=%d&page=%d&language=en&apiKey=%s",
+		s.BaseURL, url.QueryEscape(s.Query), from.Format("2006-01-02"), to.Format("2006-01-02"), s.PageSize, page, s.APIKey)
+	client := s.Client
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	resp, err := client.Get(ctx, requestURL)
+	if err != nil {
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) {
+			if apiErr := decodeNewsAPIError(statusErr.StatusCode, statusErr.Body); apiErr != nil {
+				return nil, apiErr
+			}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if apiErr := decodeNewsAPIError(resp.StatusCode, body); apiErr != nil {
+		return nil, apiErr
+	}
+	var newsResp NewsResponse
+	if err := json.Unmarshal(body, &newsResp); err != nil {
+		return nil, err
+	}
+	return newsResp.Articles, nil
+}
+
+// NewsAPIError is the error payload NewsAPI returns with status "error".
+// See https://newsapi.org/docs/errors for the list of codes.
+type NewsAPIError struct {
+	StatusCode int    `json:"-"`
+	Status     string `json:"status"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *NewsAPIError) Error() string {
+	return fmt.Sprintf("newsapi error %s (HTTP %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// decodeNewsAPIError returns a *NewsAPIError if body is a NewsAPI error payload, or nil otherwise.
+func decodeNewsAPIError(statusCode int, body []byte) *NewsAPIError {
+	var apiErr NewsAPIError
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Status != "error" {
+		return nil
+	}
+	apiErr.StatusCode = statusCode
+	return &apiErr
+}
+
+// FetchCandidates fetches the given page from every source and merges the results in source order.
+// A failing source is logged and skipped; an error is returned only if every source fails.
+func FetchCandidates(ctx context.Context, sources []NewsSource, from, to time.Time, page int) ([]Article, error) {
+	var merged []Article
+	var lastErr error
+	failures := 0
+	for _, src := range sources {
+		articles, err := src.FetchPage(ctx, from, to, page)
+		if err != nil {
+			fmt.Printf("Error fetching page %d from source %s: %v\n", page, src.Name(), err)
+			lastErr = err
+			failures++
+			continue
+		}
+		merged = append(merged, articles...)
+	}
+	if len(sources) > 0 && failures == len(sources) {
+		return nil, fmt.Errorf("all %d news sources failed, last error: %w", failures, lastErr)
+	}
+	return merged, nil
+}
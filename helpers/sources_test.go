@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagedSource serves fixed pages of articles and fails on the pages listed in fail.
+type pagedSource struct {
+	name  string
+	pages map[int][]Article
+	fail  map[int]error
+	calls []int
+}
+
+func (s *pagedSource) Name() string { return s.name }
+
+func (s *pagedSource) FetchPage(ctx context.Context, from, to time.Time, page int) ([]Article, error) {
+	s.calls = append(s.calls, page)
+	if err := s.fail[page]; err != nil {
+		return nil, err
+	}
+	return s.pages[page], nil
+}
+
+func articleURLs(articles []Article) string {
+	var urls []string
+	for _, art := range articles {
+		urls = append(urls, art.URL)
+	}
+	return strings.Join(urls, " ")
+}
+
+func TestFetchCandidatesMergesSources(t *testing.T) {
+	outage := errors.New("503 from upstream")
+	api := &pagedSource{
+		name: "api",
+		pages: map[int][]Article{
+			1: {{URL: "api-1"}, {URL: "api-2"}},
+			2: {{URL: "api-3"}},
+		},
+		fail: map[int]error{3: outage},
+	}
+	feeds := &pagedSource{
+		name:  "feeds",
+		pages: map[int][]Article{1: {{URL: "feed-1"}}},
+		fail:  map[int]error{2: errors.New("timeout")},
+	}
+	sources := []NewsSource{api, feeds}
+	ctx := context.Background()
+	to := time.Now()
+	from := to.AddDate(0, 0, -1)
+
+	tests := []struct {
+		page int
+		want string
+	}{
+		{1, "api-1 api-2 feed-1"}, // merged in source order
+		{2, "api-3"},              // the failing feeds source is skipped
+	}
+	for _, tt := range tests {
+		got, err := FetchCandidates(ctx, sources, from, to, tt.page)
+		if err != nil {
+			t.Fatalf("page %d: %v", tt.page, err)
+		}
+		if urls := articleURLs(got); urls != tt.want {
+			t.Errorf("page %d = %q, want %q", tt.page, urls, tt.want)
+		}
+	}
+
+	// One source failing while the other has run out of pages gives an empty page, not an error.
+	feeds.fail = nil
+	if got, err := FetchCandidates(ctx, sources, from, to, 3); err != nil || len(got) != 0 {
+		t.Errorf("page 3 = %v, %v; want no articles and no error", got, err)
+	}
+
+	// Only when every source fails is the page an error.
+	timeout := errors.New("timeout")
+	feeds.fail = map[int]error{3: timeout}
+	_, err := FetchCandidates(ctx, sources, from, to, 3)
+	if err == nil || !strings.Contains(err.Error(), "all 2 news sources failed") || !errors.Is(err, timeout) {
+		t.Errorf("page 3 with every source failing = %v, want an all-sources error wrapping the last one", err)
+	}
+
+	if got := fmt.Sprint(api.calls); got != "[1 2 3 3]" {
+		t.Errorf("api pages requested = %s, want [1 2 3 3]", got)
+	}
+}
@@ -0,0 +1,184 @@
+// store.go
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ArticleStore persists sent articles and digest run records.
+type ArticleStore interface {
+	SentHistory
+	// StoreArticles saves the articles that went out in the given run. URLs stored within the
+	// history window are left untouched, older entries are refreshed, and all write failures
+	// are returned together.
+	StoreArticles(ctx context.Context, runID string, articles []ArticleWithContent) error
+	// RecentArticles returns the articles stored at or after since.
+	RecentArticles(ctx context.Context, since time.Time) ([]StoredArticle, error)
+	// SaveRun writes or replaces a digest run record.
+	SaveRun(ctx context.Context, run DigestRun) error
+	// RunsOn returns the runs started on the given UTC day, oldest first.
+	RunsOn(ctx context.Context, day time.Time) ([]DigestRun, error)
+	// AcquireRunLock takes the content generation lock for the given UTC day (see RunLock).
+	// It returns a *RunLockError when the day's run is already complete or in progress.
+	AcquireRunLock(ctx context.Context, day time.Time, runID string, lease time.Duration, force bool) (RunLock, error)
+	// ReleaseRunLock records the outcome (RunStatusSent or RunStatusFailed) of the run holding lock.
+	ReleaseRunLock(ctx context.Context, lock RunLock, status string) error
+}
+
+// Store backends accepted by NewArticleStore.
+const (
+	StoreBackendDynamoDB = "dynamodb"
+	StoreBackendFile     = "file"
+)
+
+// NewArticleStore opens the backend selected by cfg.StoreBackend.
+func NewArticleStore(ctx context.Context, cfg Config) (ArticleStore, error) {
+	switch cfg.StoreBackend {
+	case "", StoreBackendDynamoDB:
+		return NewDynamoStore(ctx, cfg)
+	case StoreBackendFile:
+		return OpenFileStore(cfg.StorePath, cfg.HistoryDays)
+	default:
+		return nil, fmt.Errorf("unknown article store backend %q", cfg.StoreBackend)
+	}
+}
+
+// StoredArticle is an article as saved after a send.
+type StoredArticle struct {
+	ArticleWithContent
+	StoredAt time.Time
+	RunID    string
+}
+
+// SentHistory reports which candidate URLs were already sent recently.
+type SentHistory interface {
+	AlreadySent(ctx context.Context, urls []string) (map[string]bool, error)
+}
+
+// RecentURLs is an in-memory SentHistory.
+type RecentURLs map[string]bool
+
+// AlreadySent looks each URL up in the map.
+func (r RecentURLs) AlreadySent(ctx context.Context, urls []string) (map[string]bool, error) {
+	sent := make(map[string]bool)
+	for _, u := range urls {
+		if r[u] {
+			sent[u] = true
+		}
+	}
+	return sent, nil
+}
+
+// Fingerprints returns the non-zero content fingerprints of the given articles.
+func Fingerprints(articles []StoredArticle) []uint64 {
+	var fps []uint64
+	for _, art := range articles {
+		if art.Fingerprint != 0 {
+			fps = append(fps, art.Fingerprint)
+		}
+	}
+	return fps
+}
+
+// DigestArticlesOn returns the stored articles of the last digest sent on the given UTC day,
+// in the order they were sent. If no run record exists, it falls back to every article stored that day.
+func DigestArticlesOn(ctx context.Context, store ArticleStore, day time.Time) ([]ArticleWithContent, error) {
+	start := startOfDay(day)
+	stored, err := store.RecentArticles(ctx, start)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stored articles: %w", err)
+	}
+	return digestArticles(ctx, store, start, stored)
+}
+
+// digestArticles is DigestArticlesOn for the day starting at start, given the articles stored
+// from that day on. Articles stored on other days are ignored.
+func digestArticles(ctx context.Context, store ArticleStore, start time.Time, stored []StoredArticle) ([]ArticleWithContent, error) {
+	byURL := make(map[string]ArticleWithContent)
+	var sameDay []ArticleWithContent
+	for _, art := range stored {
+		if !art.StoredAt.Before(start) && art.StoredAt.Before(start.AddDate(0, 0, 1)) {
+			byURL[CanonicalURL(art.URL)] = art.ArticleWithContent
+			sameDay = append(sameDay, art.ArticleWithContent)
+		}
+	}
+	runs, err := store.RunsOn(ctx, start)
+	if err != nil {
+		return nil, fmt.Errorf("error reading digest runs: %w", err)
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].SendStatus != RunStatusSent {
+			continue
+		}
+		var articles []ArticleWithContent
+		for _, u := range runs[i].ArticleURLs {
+			if art, ok := byURL[CanonicalURL(u)]; ok {
+				articles = append(articles, art)
+			}
+		}
+		return articles, nil
+	}
+	return sameDay, nil
+}
+
+// LatestDigest looks back from now, one UTC day at a time for up to days days, and returns the
+// most recent day with a stored digest along with its articles (see DigestArticlesOn). The
+// stored articles of the whole window are read once and split by day.
+func LatestDigest(ctx context.Context, store ArticleStore, now time.Time, days int) (time.Time, []ArticleWithContent, error) {
+	if days <= 0 {
+		return time.Time{}, nil, nil
+	}
+	stored, err := store.RecentArticles(ctx, startOfDay(now.UTC().AddDate(0, 0, 1-days)))
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("error reading stored articles: %w", err)
+	}
+	for i := 0; i < days; i++ {
+		day := now.UTC().AddDate(0, 0, -i)
+		articles, err := digestArticles(ctx, store, startOfDay(day), stored)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		if len(articles) > 0 {
+			return day, articles, nil
+		}
+	}
+	return time.Time{}, nil, nil
+}
+
+// startOfDay returns midnight UTC of t's UTC day.
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Digest run statuses. RunStatusRunning is only used by run locks.
+const (
+	RunStatusRunning = "running"
+	RunStatusSent    = "sent"
+	RunStatusFailed  = "failed"
+)
+
+// DigestRun records what a content generation run sent and how it went.
+type DigestRun struct {
+	RunID       string    `json:"runId"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	ArticleURLs []string  `json:"articleUrls"` // in the order they appeared in the digest
+	Ranker      string    `json:"ranker"`
+	SendStatus  string    `json:"sendStatus"`
+	Error       string    `json:"error,omitempty"`
+	// Mailer and Deliveries record how the digest went out, per recipient for SES.
+	Mailer     string     `json:"mailer,omitempty"`
+	Deliveries []Delivery `json:"deliveries,omitempty"`
+}
+
+// NewRunID returns a sortable, unique ID for a content generation run.
+func NewRunID(now time.Time) string {
+	var suffix [4]byte
+	rand.Read(suffix[:])
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix[:])
+}
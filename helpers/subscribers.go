@@ -0,0 +1,477 @@
+// subscribers.go
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Subscriber statuses.
+const (
+	SubscriberPending      = "pending"
+	SubscriberActive       = "active"
+	SubscriberUnsubscribed = "unsubscribed"
+	SubscriberBounced      = "bounced"
+)
+
+// maxNameLength caps subscriber names, in characters; they are echoed in confirmation emails.
+const maxNameLength = 100
+
+// confirmResendInterval is how long a repeated subscribe request for a pending address waits
+// before another confirmation email goes out.
+const confirmResendInterval = 10 * time.Minute
+
+// ErrInvalidSubscription wraps errors caused by the request itself, such as a malformed address.
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
+// Subscriber is a person on the mailing list, keyed by lower-cased email address.
+type Subscriber struct {
+	Email         string                `json:"email"`
+	Name          string                `json:"name,omitempty"`
+	Status        string                `json:"status"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	ConfirmedAt   time.Time             `json:"confirmedAt,omitempty"`
+	UpdatedAt     time.Time             `json:"updatedAt"`
+	ExpiresAt     time.Time             `json:"expiresAt,omitempty"`     // pending records only
+	ConfirmSentAt time.Time             `json:"confirmSentAt,omitempty"` // last confirmation email
+	Preferences   SubscriberPreferences `json:"preferences"`
+}
+
+// SubscriberPreferences tailor the digest a subscriber receives from per-recipient mailers.
+type SubscriberPreferences struct {
+	// Categories limits the digest to these ranking categories; empty means all.
+	Categories []string `json:"categories,omitempty"`
+}
+
+// SubscriberStore persists subscribers.
+type SubscriberStore interface {
+	// GetSubscriber returns the subscriber with the email, or nil if there is none.
+	GetSubscriber(ctx context.Context, email string) (*Subscriber, error)
+	// PutSubscriber writes or replaces a subscriber.
+	PutSubscriber(ctx context.Context, sub Subscriber) error
+	// SubscribersByStatus returns the subscribers with the given status, oldest first.
+	SubscribersByStatus(ctx context.Context, status string) ([]Subscriber, error)
+}
+
+// NewSubscriberStore opens the backend selected by cfg.StoreBackend.
+func NewSubscriberStore(ctx context.Context, cfg Config) (SubscriberStore, error) {
+	switch cfg.StoreBackend {
+	case "", StoreBackendDynamoDB:
+		return NewDynamoStore(ctx, cfg)
+	case StoreBackendFile:
+		return OpenFileStore(cfg.StorePath, cfg.HistoryDays)
+	default:
+		return nil, fmt.Errorf("unknown subscriber store backend %q", cfg.StoreBackend)
+	}
+}
+
+// NormalizeEmail validates an address and returns its lower-cased bare form.
+func NormalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid email address %q", ErrInvalidSubscription, email)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// normalizeName trims the name and rejects control characters and names over maxNameLength.
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "", fmt.Errorf("%w: name is longer than %d characters", ErrInvalidSubscription, maxNameLength)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%w: name contains control characters", ErrInvalidSubscription)
+	}
+	return name, nil
+}
+
+// normalizePreferences trims, lower-cases and de-duplicates the categories (they are stored as a
+// DynamoDB string set) and checks them against RankingCategories.
+func normalizePreferences(prefs SubscriberPreferences) (SubscriberPreferences, error) {
+	if prefs.Categories == nil {
+		return prefs, nil
+	}
+	categories := make([]string, 0, len(prefs.Categories))
+	for _, c := range prefs.Categories {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+			categories = append(categories, c)
+		}
+	}
+	for _, c := range categories {
+		known := false
+		for _, rc := range RankingCategories {
+			if c == rc {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return prefs, fmt.Errorf("%w: unknown category %q", ErrInvalidSubscription, c)
+		}
+	}
+	prefs.Categories = uniqueStrings(categories)
+	return prefs, nil
+}
+
+// Subscribe adds or updates a subscriber and returns a message for the user. With the ses and smtp
+// mailers a new subscriber stays pending until they follow the emailed confirmation link (see
+// Confirm); with sns the topic's own confirmation email does that job, so they are active at once.
+func Subscribe(ctx context.Context, cfg Config, store SubscriberStore, email, name string, prefs SubscriberPreferences) (string, error) {
+	sender, err := NewMessageSender(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	return subscribe(ctx, cfg, store, sender, email, name, prefs)
+}
+
+// subscribe implements Subscribe; a nil sender means the sns mailer. Repeated requests for a
+// pending address send at most one confirmation email per confirmResendInterval.
+func subscribe(ctx context.Context, cfg Config, store SubscriberStore, sender MessageSender, email, name string, prefs SubscriberPreferences) (string, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+	name, err = normalizeName(name)
+	if err != nil {
+		return "", err
+	}
+	prefs, err = normalizePreferences(prefs)
+	if err != nil {
+		return "", err
+	}
+	existing, err := store.GetSubscriber(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	const (
+		activeNow  = "Subscription successful! Please check your email for confirmation."
+		checkEmail = "Almost done! Please check your email and follow the link to confirm your subscription."
+	)
+	if existing != nil && existing.Status == SubscriberActive {
+		// Anyone can post any address, so an active subscription is never changed here and the reply
+		// is the same as for a new address. Changes are emailed as a signed link instead (see Confirm).
+		if sender == nil {
+			return activeNow, nil
+		}
+		if (name == "" && prefs.Categories == nil) || now.Sub(existing.ConfirmSentAt) < confirmResendInterval {
+			return checkEmail, nil
+		}
+		update := encodeProfileUpdate(email, name, prefs)
+		if err := sendConfirmation(ctx, cfg, sender, *existing, TokenUpdate, update, now.Add(cfg.ConfirmExpiry())); err != nil {
+			return "", err
+		}
+		// Only the time of the email is recorded, to throttle further ones.
+		existing.ConfirmSentAt = now
+		if err := store.PutSubscriber(ctx, *existing); err != nil {
+			return "", err
+		}
+		return checkEmail, nil
+	}
+
+	sub := Subscriber{Email: email, CreatedAt: now}
+	if existing != nil {
+		sub = *existing
+	}
+	if name != "" {
+		sub.Name = name
+	}
+	if prefs.Categories != nil {
+		sub.Preferences = prefs
+	}
+	sub.UpdatedAt = now
+	if sender == nil {
+		if err := activate(ctx, cfg, store, sub, now); err != nil {
+			return "", err
+		}
+		return activeNow, nil
+	}
+	if sub.Status == SubscriberPending && !sub.Expired(now) && now.Sub(sub.ConfirmSentAt) < confirmResendInterval {
+		// Keep the name and preferences, but do not send the same address another email yet.
+		if err := store.PutSubscriber(ctx, sub); err != nil {
+			return "", err
+		}
+		return checkEmail, nil
+	}
+	sub.Status = SubscriberPending
+	sub.ExpiresAt = now.Add(cfg.ConfirmExpiry())
+	if err := store.PutSubscriber(ctx, sub); err != nil {
+		return "", err
+	}
+	if err := sendConfirmation(ctx, cfg, sender, sub, TokenConfirm, sub.Email, sub.ExpiresAt); err != nil {
+		return "", err
+	}
+	// Record the email only once it is out, so a failed send can be retried straight away.
+	sub.ConfirmSentAt = now
+	if err := store.PutSubscriber(ctx, sub); err != nil {
+		return "", err
+	}
+	return checkEmail, nil
+}
+
+// sendConfirmation emails the subscriber a link to Confirm with a token signed for purpose and
+// subject: activating a pending subscription (TokenConfirm) or changing an active one (TokenUpdate).
+func sendConfirmation(ctx context.Context, cfg Config, sender MessageSender, sub Subscriber, purpose, subject string, expires time.Time) error {
+	if cfg.ConfirmURL == "" {
+		return fmt.Errorf("confirmUrl is not configured")
+	}
+	secret, err := ConfirmationSecret(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	link, err := url.Parse(cfg.ConfirmURL)
+	if err != nil {
+		return fmt.Errorf("invalid confirmUrl: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", SignToken(secret, purpose, subject, expires))
+	link.RawQuery = query.Encode()
+
+	data := Confirmation{
+		Subject:    ConfirmationSubject,
+		Name:       sub.Name,
+		Email:      sub.Email,
+		ConfirmURL: link.String(),
+		Expires:    expires,
+	}
+	if purpose == TokenUpdate {
+		data.Subject = UpdateSubject
+		data.Update = true
+	}
+	email, err := RenderConfirmation(data)
+	if err != nil {
+		return err
+	}
+	if _, err := sender.SendMessage(ctx, sub.Email, email, nil); err != nil {
+		return fmt.Errorf("failed to send confirmation email to %s: %w", sub.Email, err)
+	}
+	fmt.Printf("Sent confirmation email to %s\n", sub.Email)
+	return nil
+}
+
+// encodeProfileUpdate packs the changes requested for an active subscriber into the subject of a
+// TokenUpdate token: the address, the name and the categories, separated by tabs, which names
+// cannot contain (see normalizeName). Categories left unchanged are written as "-".
+func encodeProfileUpdate(email, name string, prefs SubscriberPreferences) string {
+	categories := "-"
+	if prefs.Categories != nil {
+		categories = strings.Join(prefs.Categories, ",")
+	}
+	return email + "\t" + name + "\t" + categories
+}
+
+// decodeProfileUpdate reverses encodeProfileUpdate.
+func decodeProfileUpdate(subject string) (email, name string, prefs SubscriberPreferences, err error) {
+	parts := strings.Split(subject, "\t")
+	if len(parts) != 3 {
+		return "", "", prefs, ErrInvalidToken
+	}
+	switch parts[2] {
+	case "-":
+	case "":
+		prefs.Categories = []string{}
+	default:
+		prefs.Categories = strings.Split(parts[2], ",")
+	}
+	return parts[0], parts[1], prefs, nil
+}
+
+// Confirm activates the pending subscriber named by a confirmation token, or applies the changes
+// carried by a TokenUpdate token to an active one, and returns a message for the user. Bad,
+// expired or stale tokens are reported as ErrInvalidSubscription.
+func Confirm(ctx context.Context, cfg Config, store SubscriberStore, token string) (string, error) {
+	secret, err := ConfirmationSecret(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	email, err := VerifyToken(secret, TokenConfirm, token, now)
+	if errors.Is(err, ErrInvalidToken) {
+		if update, updateErr := VerifyToken(secret, TokenUpdate, token, now); !errors.Is(updateErr, ErrInvalidToken) {
+			if updateErr != nil {
+				return "", fmt.Errorf("%w: %w", ErrInvalidSubscription, updateErr)
+			}
+			return applyProfileUpdate(ctx, store, update, now)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+	}
+	sub, err := store.GetSubscriber(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	if sub != nil && sub.Status == SubscriberActive {
+		return fmt.Sprintf("%s is already confirmed.", email), nil
+	}
+	if sub == nil || sub.Status != SubscriberPending {
+		return "", fmt.Errorf("%w: there is no pending subscription for %s, please subscribe again", ErrInvalidSubscription, email)
+	}
+	if err := activate(ctx, cfg, store, *sub, now); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Thanks for confirming! %s will receive the next daily digest.", email), nil
+}
+
+// applyProfileUpdate applies the changes of a verified TokenUpdate subject to the active subscriber.
+func applyProfileUpdate(ctx context.Context, store SubscriberStore, update string, now time.Time) (string, error) {
+	email, name, prefs, err := decodeProfileUpdate(update)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+	}
+	if prefs, err = normalizePreferences(prefs); err != nil {
+		return "", err
+	}
+	sub, err := store.GetSubscriber(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	if sub == nil || sub.Status != SubscriberActive {
+		return "", fmt.Errorf("%w: there is no active subscription for %s, please subscribe again", ErrInvalidSubscription, email)
+	}
+	if name != "" {
+		sub.Name = name
+	}
+	if prefs.Categories != nil {
+		sub.Preferences = prefs
+	}
+	sub.UpdatedAt = now
+	if err := store.PutSubscriber(ctx, *sub); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Your subscription for %s has been updated.", email), nil
+}
+
+// activate marks the subscriber active and, with the sns mailer, subscribes the address to the SNS
+// topic too. The ses and smtp mailers send to the table directly, and a topic subscription would
+// only get the person a second confirmation email from SNS.
+func activate(ctx context.Context, cfg Config, store SubscriberStore, sub Subscriber, now time.Time) error {
+	sub.Status = SubscriberActive
+	sub.ConfirmedAt = now
+	sub.ExpiresAt = time.Time{}
+	sub.ConfirmSentAt = time.Time{}
+	sub.UpdatedAt = now
+	if err := store.PutSubscriber(ctx, sub); err != nil {
+		return err
+	}
+	if cfg.SNSSubscriptions && cfg.Mailer == MailerSNS {
+		return SubscribeUser(ctx, cfg, sub.Email)
+	}
+	return nil
+}
+
+// Expired reports whether a pending subscriber's confirmation window closed before now.
+func (s Subscriber) Expired(now time.Time) bool {
+	return s.Status == SubscriberPending && !s.ExpiresAt.IsZero() && now.After(s.ExpiresAt)
+}
+
+// Unsubscribe marks the subscriber as unsubscribed and, if cfg.SNSSubscriptions is set, removes
+// the SNS subscription too. It returns a message for the user.
+func Unsubscribe(ctx context.Context, cfg Config, store SubscriberStore, email string) (string, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+	sub, err := store.GetSubscriber(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	msg := fmt.Sprintf("No active subscription found for %s", email)
+	if sub != nil && sub.Status != SubscriberUnsubscribed {
+		sub.Status = SubscriberUnsubscribed
+		sub.ExpiresAt = time.Time{}
+		sub.UpdatedAt = time.Now().UTC()
+		if err := store.PutSubscriber(ctx, *sub); err != nil {
+			return "", err
+		}
+		msg = fmt.Sprintf("Successfully unsubscribed %s", email)
+	}
+	if cfg.SNSSubscriptions {
+		snsMsg, err := UnsubscribeUser(ctx, cfg, email)
+		if err != nil {
+			return "", err
+		}
+		if sub == nil {
+			msg = snsMsg
+		}
+	}
+	return msg, nil
+}
+
+// UnsubscribeTokenEmail verifies the token from an unsubscribe link and returns the address it
+// was issued for. Bad or expired tokens are reported as ErrInvalidSubscription.
+func UnsubscribeTokenEmail(ctx context.Context, cfg Config, token string) (string, error) {
+	secret, err := ConfirmationSecret(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	email, err := VerifyToken(secret, TokenUnsubscribe, token, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+	}
+	return email, nil
+}
+
+// FilterArticles returns the articles matching the subscriber's category preferences, or all of
+// them if the preferences would leave nothing to send.
+func (s Subscriber) FilterArticles(articles []ArticleWithContent) []ArticleWithContent {
+	if len(s.Preferences.Categories) == 0 {
+		return articles
+	}
+	want := make(map[string]bool, len(s.Preferences.Categories))
+	for _, c := range s.Preferences.Categories {
+		want[c] = true
+	}
+	var filtered []ArticleWithContent
+	for _, art := range articles {
+		if want[art.Category] {
+			filtered = append(filtered, art)
+		}
+	}
+	if len(filtered) == 0 {
+		return articles
+	}
+	return filtered
+}
+
+// ImportTopicSubscribers adds the confirmed email subscriptions of the SNS topic that are not in
+// the store yet as active subscribers, and returns how many were added.
+func ImportTopicSubscribers(ctx context.Context, cfg Config, store SubscriberStore) (int, error) {
+	topicSubs, err := ListTopicSubscribers(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, ts := range topicSubs {
+		if ts.SubscriptionARN == "PendingConfirmation" {
+			continue
+		}
+		email, err := NormalizeEmail(ts.Email)
+		if err != nil {
+			fmt.Println("Skipping topic subscriber:", err)
+			continue
+		}
+		existing, err := store.GetSubscriber(ctx, email)
+		if err != nil {
+			return added, err
+		}
+		if existing != nil {
+			continue
+		}
+		now := time.Now().UTC()
+		sub := Subscriber{Email: email, Status: SubscriberActive, CreatedAt: now, ConfirmedAt: now, UpdatedAt: now}
+		if err := store.PutSubscriber(ctx, sub); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
@@ -0,0 +1,265 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizePreferences(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{}, []string{}},
+		{[]string{"health", "health"}, []string{"health"}},
+		{[]string{" Science", "health ", "SCIENCE", ""}, []string{"science", "health"}},
+	}
+	for _, tt := range tests {
+		got, err := normalizePreferences(SubscriberPreferences{Categories: tt.in})
+		if err != nil {
+			t.Errorf("normalizePreferences(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Categories, tt.want) {
+			t.Errorf("normalizePreferences(%q) = %q, want %q", tt.in, got.Categories, tt.want)
+		}
+	}
+	if _, err := normalizePreferences(SubscriberPreferences{Categories: []string{"gossip"}}); !errors.Is(err, ErrInvalidSubscription) {
+		t.Errorf("unknown category: err = %v, want ErrInvalidSubscription", err)
+	}
+}
+
+func TestSubscribeStoresNormalizedCategories(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.SNSSubscriptions = false // keep the test off the network
+	store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefs := SubscriberPreferences{Categories: []string{"health", " Health", "science"}}
+	if _, err := Subscribe(ctx, cfg, store, "Someone@Example.com", "Sam", prefs); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	sub, err := store.GetSubscriber(ctx, "someone@example.com")
+	if err != nil || sub == nil {
+		t.Fatalf("GetSubscriber = %v, %v", sub, err)
+	}
+	if want := []string{"health", "science"}; !reflect.DeepEqual(sub.Preferences.Categories, want) {
+		t.Errorf("categories = %q, want %q", sub.Preferences.Categories, want)
+	}
+}
+
+// failingSender fails every send.
+type failingSender struct{}
+
+func (failingSender) SendMessage(ctx context.Context, to string, email RenderedEmail, headers map[string]string) (string, error) {
+	return "", errors.New("throttled")
+}
+
+// recordingSender records the messages it is asked to send.
+type recordingSender struct {
+	sent   []string
+	emails []RenderedEmail
+}
+
+func (s *recordingSender) SendMessage(ctx context.Context, to string, email RenderedEmail, headers map[string]string) (string, error) {
+	s.sent = append(s.sent, to)
+	s.emails = append(s.emails, email)
+	return "id", nil
+}
+
+// tokenFromEmail returns the token of the link in a confirmation email.
+func tokenFromEmail(t *testing.T, email RenderedEmail) string {
+	t.Helper()
+	for _, field := range strings.Fields(email.Text) {
+		if u, err := url.Parse(field); err == nil && u.Query().Get("token") != "" {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("no link with a token in %q", email.Text)
+	return ""
+}
+
+func TestSubscribeConfirmationCooldown(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Mailer = MailerSES // SNSSubscriptions stays true; a ses subscriber must not touch the topic
+	cfg.ConfirmURL = "https://example.com/confirm"
+	cfg.ConfirmSecret = "0123456789abcdef"
+	store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{}
+	for i := 0; i < 3; i++ {
+		if _, err := subscribe(ctx, cfg, store, sender, "someone@example.com", "Sam", SubscriberPreferences{}); err != nil {
+			t.Fatalf("subscribe #%d: %v", i+1, err)
+		}
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("sent %d confirmation emails, want 1", len(sender.sent))
+	}
+
+	// Once the interval has passed the link can be sent again.
+	sub, _ := store.GetSubscriber(ctx, "someone@example.com")
+	sub.ConfirmSentAt = sub.ConfirmSentAt.Add(-confirmResendInterval)
+	if err := store.PutSubscriber(ctx, *sub); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subscribe(ctx, cfg, store, sender, "someone@example.com", "", SubscriberPreferences{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 2 {
+		t.Errorf("sent %d confirmation emails after the interval, want 2", len(sender.sent))
+	}
+
+	// Confirming with the ses mailer activates the subscriber without calling SNS.
+	token := SignToken(cfg.ConfirmSecret, TokenConfirm, "someone@example.com", time.Now().Add(time.Hour))
+	if _, err := Confirm(ctx, cfg, store, token); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if sub, _ := store.GetSubscriber(ctx, "someone@example.com"); sub.Status != SubscriberActive || !sub.ConfirmSentAt.IsZero() {
+		t.Errorf("after Confirm: status %q, confirmSentAt %v", sub.Status, sub.ConfirmSentAt)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	if got, err := normalizeName("  Sam  "); err != nil || got != "Sam" {
+		t.Errorf(`normalizeName("  Sam  ") = %q, %v`, got, err)
+	}
+	if _, err := normalizeName(strings.Repeat("é", maxNameLength)); err != nil {
+		t.Errorf("name of %d characters: %v", maxNameLength, err)
+	}
+	for _, name := range []string{strings.Repeat("a", maxNameLength+1), "Sam\r\nBcc: x@example.com"} {
+		if _, err := normalizeName(name); !errors.Is(err, ErrInvalidSubscription) {
+			t.Errorf("normalizeName(%q): err = %v, want ErrInvalidSubscription", name, err)
+		}
+	}
+}
+
+func TestUnsubscribeLinkRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConfirmSecret = "0123456789abcdef"
+	cfg.UnsubscribeURL = "https://fn.example.com/unsubscribe?source=digest"
+	link := unsubscribeLink(cfg, cfg.ConfirmSecret, "someone@example.com")
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("source") != "digest" || strings.Contains(link, "someone") {
+		t.Errorf("link %s should keep the configured query and not reveal the address", link)
+	}
+	email, err := UnsubscribeTokenEmail(context.Background(), cfg, u.Query().Get("token"))
+	if err != nil || email != "someone@example.com" {
+		t.Errorf("UnsubscribeTokenEmail = %q, %v", email, err)
+	}
+
+	confirm := SignToken(cfg.ConfirmSecret, TokenConfirm, "someone@example.com", time.Now().Add(time.Hour))
+	if _, err := UnsubscribeTokenEmail(context.Background(), cfg, confirm); !errors.Is(err, ErrInvalidSubscription) {
+		t.Errorf("confirmation token accepted for unsubscribing: err = %v", err)
+	}
+
+	headers := unsubscribeHeaders(link)
+	if headers["List-Unsubscribe"] != "<"+link+">" || headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("unsubscribeHeaders = %v", headers)
+	}
+	if unsubscribeHeaders("") != nil {
+		t.Error("unsubscribeHeaders without a link should be nil")
+	}
+}
+
+func TestSubscribeActiveAddressNeedsConfirmedChanges(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Mailer = MailerSES
+	cfg.SNSSubscriptions = false
+	cfg.ConfirmURL = "https://example.com/confirm"
+	cfg.ConfirmSecret = "0123456789abcdef"
+	store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active := Subscriber{Email: "someone@example.com", Name: "Sam", Status: SubscriberActive, Preferences: SubscriberPreferences{Categories: []string{"health"}}}
+	if err := store.PutSubscriber(ctx, active); err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{}
+	pendingMsg, err := subscribe(ctx, cfg, store, sender, "new@example.com", "", SubscriberPreferences{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without changes nothing is sent, and the reply is the one a new address gets.
+	msg, err := subscribe(ctx, cfg, store, sender, "someone@example.com", "", SubscriberPreferences{})
+	if err != nil || msg != pendingMsg || len(sender.sent) != 1 {
+		t.Fatalf("subscribe active without changes = %q, %v with %d emails", msg, err, len(sender.sent))
+	}
+
+	prefs := SubscriberPreferences{Categories: []string{}}
+	for i := 0; i < 2; i++ {
+		if msg, err = subscribe(ctx, cfg, store, sender, "someone@example.com", "Mallory", prefs); err != nil || msg != pendingMsg {
+			t.Fatalf("subscribe active with changes = %q, %v", msg, err)
+		}
+	}
+	if len(sender.sent) != 2 || sender.sent[1] != "someone@example.com" {
+		t.Fatalf("sent %v, want one update email to the subscriber", sender.sent)
+	}
+	if sub, _ := store.GetSubscriber(ctx, "someone@example.com"); sub.Name != "Sam" || len(sub.Preferences.Categories) != 1 {
+		t.Fatalf("active subscriber changed without confirmation: %+v", sub)
+	}
+
+	if _, err := Confirm(ctx, cfg, store, tokenFromEmail(t, sender.emails[1])); err != nil {
+		t.Fatalf("Confirm update: %v", err)
+	}
+	sub, _ := store.GetSubscriber(ctx, "someone@example.com")
+	if sub.Name != "Mallory" || len(sub.Preferences.Categories) != 0 || sub.Status != SubscriberActive {
+		t.Errorf("after confirming the update: %+v", sub)
+	}
+}
+
+func TestProfileUpdateRoundTrip(t *testing.T) {
+	tests := []SubscriberPreferences{{}, {Categories: []string{}}, {Categories: []string{"health", "science"}}}
+	for _, prefs := range tests {
+		email, name, got, err := decodeProfileUpdate(encodeProfileUpdate("a@example.com", "Ann Lee", prefs))
+		if err != nil || email != "a@example.com" || name != "Ann Lee" || !reflect.DeepEqual(got, prefs) {
+			t.Errorf("round trip of %+v = %q, %q, %+v, %v", prefs, email, name, got, err)
+		}
+	}
+}
+
+func TestSubscribeRetriesAfterFailedConfirmation(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.Mailer = MailerSES
+	cfg.ConfirmURL = "https://example.com/confirm"
+	cfg.ConfirmSecret = "0123456789abcdef"
+	store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"), 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subscribe(ctx, cfg, store, failingSender{}, "someone@example.com", "", SubscriberPreferences{}); err == nil {
+		t.Fatal("subscribe with a failing sender succeeded")
+	}
+	if sub, _ := store.GetSubscriber(ctx, "someone@example.com"); sub == nil || !sub.ConfirmSentAt.IsZero() {
+		t.Fatalf("after a failed send: %+v, want a pending record without ConfirmSentAt", sub)
+	}
+
+	sender := &recordingSender{}
+	if _, err := subscribe(ctx, cfg, store, sender, "someone@example.com", "", SubscriberPreferences{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("retry sent %d confirmation emails, want 1", len(sender.sent))
+	}
+	if sub, _ := store.GetSubscriber(ctx, "someone@example.com"); sub.ConfirmSentAt.IsZero() {
+		t.Error("ConfirmSentAt not recorded after a successful send")
+	}
+}
@@ -0,0 +1,72 @@
+// token.go
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token purposes, so a token issued for one action cannot be used for another.
+const (
+	TokenConfirm     = "confirm"
+	TokenUpdate      = "update"
+	TokenUnsubscribe = "unsubscribe"
+)
+
+// minTokenSecretLen is the shortest secret tokens may be signed with.
+const minTokenSecretLen = 16
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, tampered with or for another purpose.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpiredToken is returned for correctly signed tokens past their expiry.
+	ErrExpiredToken = errors.New("token has expired")
+)
+
+// SignToken returns a URL-safe token for email and purpose that is valid until expires. The token
+// is the payload "purpose\nemail\nexpiry" and its HMAC-SHA256 under secret, each base64url-encoded
+// and joined by a dot.
+func SignToken(secret, purpose, email string, expires time.Time) string {
+	payload := purpose + "\n" + email + "\n" + strconv.FormatInt(expires.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(tokenMAC(secret, payload))
+}
+
+// VerifyToken checks the token's signature, purpose and expiry and returns the email it was issued for.
+func VerifyToken(secret, purpose, token string, now time.Time) (string, error) {
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encMAC)
+	if err != nil || !hmac.Equal(mac, tokenMAC(secret, string(payload))) {
+		return "", ErrInvalidToken
+	}
+	parts := strings.Split(string(payload), "\n")
+	if len(parts) != 3 || parts[0] != purpose {
+		return "", ErrInvalidToken
+	}
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if now.Unix() > expiry {
+		return "", ErrExpiredToken
+	}
+	return parts[1], nil
+}
+
+func tokenMAC(secret, payload string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,87 @@
+// invocation.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// scheduledInvocation is an EventBridge event, normally the daily "Scheduled Event".
+// Generation switches may be set in its detail or, as before the event was typed, at the top level.
+type scheduledInvocation struct {
+	Event   events.CloudWatchEvent
+	Options generationOptions
+}
+
+// manualInvocation is a direct admin invocation (aws lambda invoke or the console), e.g.
+// {"admin": {"action": "generate", "dryRun": true}}. Only callers with lambda:InvokeFunction can send it.
+type manualInvocation struct {
+	Action string `json:"action"`
+	generationOptions
+}
+
+// httpInvocation is a Lambda Function URL request.
+type httpInvocation struct {
+	Request events.LambdaFunctionURLRequest
+}
+
+// generationOptions are the content generation switches carried by scheduled and manual invocations.
+type generationOptions struct {
+	DryRun bool `json:"dryRun"`
+	Force  bool `json:"force"`
+}
+
+// decodeInvocation tells the three kinds of event apart by shape and decodes the matching one.
+// It returns a *scheduledInvocation, *manualInvocation or *httpInvocation.
+func decodeInvocation(raw json.RawMessage) (interface{}, error) {
+	var shape struct {
+		Source         string          `json:"source"`
+		DetailType     string          `json:"detail-type"`
+		Admin          json.RawMessage `json:"admin"`
+		RequestContext struct {
+			HTTP struct {
+				Method string `json:"method"`
+			} `json:"http"`
+		} `json:"requestContext"`
+		generationOptions // top-level switches on scheduled events
+	}
+	if err := json.Unmarshal(raw, &shape); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	switch {
+	case len(shape.Admin) > 0:
+		var inv manualInvocation
+		if err := json.Unmarshal(shape.Admin, &inv); err != nil {
+			return nil, fmt.Errorf("failed to parse admin event: %w", err)
+		}
+		return &inv, nil
+
+	case shape.RequestContext.HTTP.Method != "":
+		var inv httpInvocation
+		if err := json.Unmarshal(raw, &inv.Request); err != nil {
+			return nil, fmt.Errorf("failed to parse Function URL request: %w", err)
+		}
+		return &inv, nil
+
+	case shape.Source != "" || shape.DetailType != "":
+		if shape.Source != "aws.events" {
+			return nil, fmt.Errorf("unsupported EventBridge event from source %q", shape.Source)
+		}
+		var inv scheduledInvocation
+		if err := json.Unmarshal(raw, &inv.Event); err != nil {
+			return nil, fmt.Errorf("failed to parse EventBridge event: %w", err)
+		}
+		if len(inv.Event.Detail) > 0 && string(inv.Event.Detail) != "null" {
+			if err := json.Unmarshal(inv.Event.Detail, &inv.Options); err != nil {
+				return nil, fmt.Errorf("failed to parse EventBridge detail: %w", err)
+			}
+		}
+		inv.Options.DryRun = inv.Options.DryRun || shape.DryRun
+		inv.Options.Force = inv.Options.Force || shape.Force
+		return &inv, nil
+	}
+	return nil, fmt.Errorf("unrecognized event: expected an EventBridge event, an admin event or a Function URL request")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeInvocationFixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		want interface{}
+	}{
+		{"scheduled.json", &scheduledInvocation{}},
+		{"scheduled-dry-run.json", &scheduledInvocation{}},
+		{"admin-generate.json", &manualInvocation{}},
+		{"http-subscribe.json", &httpInvocation{}},
+		{"http-unsubscribe.json", &httpInvocation{}},
+		{"http-confirm.json", &httpInvocation{}},
+		{"http-latest-articles.json", &httpInvocation{}},
+		{"http-health.json", &httpInvocation{}},
+		{"http-options.json", &httpInvocation{}},
+	}
+	files, err := filepath.Glob(filepath.Join("events", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(tests) {
+		t.Errorf("events/ has %d fixtures, the test covers %d", len(files), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			raw, err := ioutil.ReadFile(filepath.Join("events", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			inv, err := decodeInvocation(raw)
+			if err != nil {
+				t.Fatalf("decodeInvocation: %v", err)
+			}
+			if got, want := fmt.Sprintf("%T", inv), fmt.Sprintf("%T", tt.want); got != want {
+				t.Errorf("decoded as %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeInvocationOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		want  generationOptions
+	}{
+		{"no options", `{"source": "aws.events", "detail-type": "Scheduled Event", "detail": {}}`, generationOptions{}},
+		{"detail", `{"source": "aws.events", "detail": {"dryRun": true, "force": true}}`, generationOptions{DryRun: true, Force: true}},
+		{"top level", `{"source": "aws.events", "force": true}`, generationOptions{Force: true}},
+		{"both", `{"source": "aws.events", "dryRun": true, "detail": {"force": true}}`, generationOptions{DryRun: true, Force: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := decodeInvocation(json.RawMessage(tt.event))
+			if err != nil {
+				t.Fatalf("decodeInvocation: %v", err)
+			}
+			sched, ok := inv.(*scheduledInvocation)
+			if !ok {
+				t.Fatalf("got %T, want *scheduledInvocation", inv)
+			}
+			if sched.Options != tt.want {
+				t.Errorf("options = %+v, want %+v", sched.Options, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInvocationRejects(t *testing.T) {
+	for _, event := range []string{
+		`{}`,
+		`{"source": "aws.s3", "detail-type": "Object Created"}`,
+		`not json`,
+	} {
+		if inv, err := decodeInvocation(json.RawMessage(event)); err == nil {
+			t.Errorf("decodeInvocation(%s) = %T, want an error", event, inv)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+// router.go
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"positive-news/helpers"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// routeHandler handles one method and path of the Function URL.
+type routeHandler func(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse
+
+// route binds a method and path to a handler.
+type route struct {
+	method  string
+	path    string
+	handler routeHandler
+}
+
+// routes lists every Function URL endpoint. POST / with an "action" field is the original
+// single endpoint, kept for pages that still post to it.
+var routes = []route{
+	{http.MethodPost, "/subscribe", handleSubscribeRoute},
+	{http.MethodPost, "/unsubscribe", handleUnsubscribeRoute},
+	{http.MethodGet, "/unsubscribe", handleUnsubscribeLinkRoute},
+	{http.MethodGet, "/confirm", handleConfirmRoute},
+	{http.MethodGet, "/articles/latest", handleLatestArticlesRoute},
+	{http.MethodGet, "/health", handleHealthRoute},
+	{http.MethodPost, "/", handleActionRoute},
+}
+
+// latestDigestDays is how far back GET /articles/latest looks for a digest.
+const latestDigestDays = 7
+
+// latestArticlesMaxAge is how long browsers, and the warm Lambda container, reuse a
+// GET /articles/latest response; the digest changes once a day.
+const latestArticlesMaxAge = 5 * time.Minute
+
+// latestArticles caches the last successful GET /articles/latest response.
+var latestArticles struct {
+	sync.Mutex
+	resp    events.LambdaFunctionURLResponse
+	expires time.Time
+}
+
+// routeRequest dispatches a Function URL request by method and path. OPTIONS preflights get
+// 200 for any known path, a known path with the wrong method gets 405, anything else 404.
+func routeRequest(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	method := req.RequestContext.HTTP.Method
+	path := req.RawPath
+	if path == "" {
+		path = req.RequestContext.HTTP.Path
+	}
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	if path == "" {
+		path = "/"
+	}
+	fmt.Printf("%s %s\n", method, path)
+
+	var allowed []string
+	for _, r := range routes {
+		if r.path != path {
+			continue
+		}
+		if r.method == method {
+			return r.handler(ctx, cfg, req)
+		}
+		allowed = append(allowed, r.method)
+	}
+	if len(allowed) == 0 {
+		return buildResponse(404, fmt.Sprintf("No route for %s", path))
+	}
+	if method == http.MethodOptions {
+		return buildResponse(200, "OK")
+	}
+	sort.Strings(allowed)
+	resp := buildResponse(405, fmt.Sprintf("Method %s not allowed on %s", method, path))
+	resp.Headers["Allow"] = strings.Join(append(allowed, http.MethodOptions), ", ")
+	return resp
+}
+
+// decodeBody unmarshals the JSON request body into v, decoding base64 bodies first.
+func decodeBody(req events.LambdaFunctionURLRequest, v interface{}) error {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decode body: %w", err)
+		}
+		body = decoded
+	}
+	if len(body) == 0 {
+		return fmt.Errorf("missing request body")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse body: %w", err)
+	}
+	return nil
+}
+
+// handleSubscribeRoute handles POST /subscribe with {"email": ..., "name": ..., "categories": [...]}.
+func handleSubscribeRoute(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	var sub SubscriptionRequest
+	if err := decodeBody(req, &sub); err != nil {
+		return buildResponse(400, err.Error())
+	}
+	if sub.Email == "" {
+		return buildResponse(400, "Email is required for subscription.")
+	}
+	if cfg.ConfirmURL == "" && req.RequestContext.DomainName != "" {
+		// Confirmation links come back to this Function URL unless configured otherwise.
+		cfg.ConfirmURL = "https://" + req.RequestContext.DomainName + "/confirm"
+	}
+	fmt.Printf("Processing subscription for %s\n", sub.Email)
+	msg, err := handleSubscription(ctx, cfg, sub)
+	if err != nil {
+		return buildResponse(errorStatus(err), fmt.Sprintf("Subscription error: %v", err))
+	}
+	return buildResponse(200, msg)
+}
+
+// handleUnsubscribeRoute handles POST /unsubscribe with {"email": ...}, and POST /unsubscribe?token=...
+// from the page of handleUnsubscribeLinkRoute or a mail client's one-click unsubscribe (RFC 8058).
+func handleUnsubscribeRoute(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	if token := req.QueryStringParameters["token"]; token != "" {
+		return handleUnsubscribeToken(ctx, cfg, token)
+	}
+	var sub SubscriptionRequest
+	if err := decodeBody(req, &sub); err != nil {
+		return buildResponse(400, err.Error())
+	}
+	if sub.Email == "" {
+		return buildResponse(400, "Email is required for unsubscription.")
+	}
+	fmt.Printf("Processing unsubscription for %s\n", sub.Email)
+	msg, err := handleUnsubscription(ctx, cfg, sub.Email)
+	if err != nil {
+		return buildResponse(errorStatus(err), fmt.Sprintf("Unsubscription error: %v", err))
+	}
+	return buildResponse(200, msg)
+}
+
+// handleUnsubscribeLinkRoute handles GET /unsubscribe?token=..., the link in the digest. Opening it
+// only shows a button that posts the token back, so link scanners cannot unsubscribe anyone.
+func handleUnsubscribeLinkRoute(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	token := req.QueryStringParameters["token"]
+	if token == "" {
+		return buildHTMLResponse(400, "Unsubscribe failed", "The unsubscribe link is missing its token.", cfg.Website())
+	}
+	email, err := helpers.UnsubscribeTokenEmail(ctx, cfg, token)
+	if err != nil {
+		return unsubscribeErrorPage(err, cfg)
+	}
+	page := htmlPageData{
+		Title:      "Unsubscribe",
+		Message:    fmt.Sprintf("Stop sending the daily digest to %s?", email),
+		FormAction: "/unsubscribe?" + url.Values{"token": {token}}.Encode(),
+		FormButton: "Unsubscribe",
+		WebsiteURL: cfg.Website(),
+	}
+	return renderHTMLPage(200, page)
+}
+
+// handleUnsubscribeToken unsubscribes the address named by a signed unsubscribe token.
+func handleUnsubscribeToken(ctx context.Context, cfg helpers.Config, token string) events.LambdaFunctionURLResponse {
+	email, err := helpers.UnsubscribeTokenEmail(ctx, cfg, token)
+	if err == nil {
+		fmt.Printf("Processing unsubscription link for %s\n", email)
+		_, err = handleUnsubscription(ctx, cfg, email)
+	}
+	if err != nil {
+		return unsubscribeErrorPage(err, cfg)
+	}
+	return buildHTMLResponse(200, "Unsubscribed", fmt.Sprintf("%s will not receive the daily digest any more.", email), cfg.Website())
+}
+
+// unsubscribeErrorPage reports a failed unsubscribe link without echoing internal errors.
+func unsubscribeErrorPage(err error, cfg helpers.Config) events.LambdaFunctionURLResponse {
+	fmt.Println("Unsubscribe error:", err)
+	status := errorStatus(err)
+	msg := "Something went wrong while unsubscribing. Please try again later."
+	if status == 400 {
+		msg = "This unsubscribe link is invalid or has expired."
+	}
+	return buildHTMLResponse(status, "Unsubscribe failed", msg, cfg.Website())
+}
+
+// handleConfirmRoute handles GET /confirm?token=..., the link in the confirmation email. It answers
+// with a small HTML page since it is opened in a browser.
+func handleConfirmRoute(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	token := req.QueryStringParameters["token"]
+	if token == "" {
+		return buildHTMLResponse(400, "Confirmation failed", "The confirmation link is missing its token.", cfg.Website())
+	}
+	store, err := helpers.NewSubscriberStore(ctx, cfg)
+	if err != nil {
+		return confirmErrorPage(fmt.Errorf("error opening subscriber store: %w", err), cfg)
+	}
+	msg, err := helpers.Confirm(ctx, cfg, store, token)
+	if err != nil {
+		return confirmErrorPage(err, cfg)
+	}
+	fmt.Println(msg)
+	return buildHTMLResponse(200, "Subscription confirmed", msg, cfg.Website())
+}
+
+// confirmErrorPage reports a failed confirmation link without echoing internal errors.
+func confirmErrorPage(err error, cfg helpers.Config) events.LambdaFunctionURLResponse {
+	fmt.Println("Confirmation error:", err)
+	status := errorStatus(err)
+	msg := "Something went wrong while confirming your subscription. Please try again later."
+	if status == 400 {
+		msg = "This confirmation link is invalid or has expired. Please subscribe again."
+	}
+	return buildHTMLResponse(status, "Confirmation failed", msg, cfg.Website())
+}
+
+// htmlPage is the page returned by routes meant to be opened in a browser.
+var htmlPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body style="font-family:Arial, Helvetica, sans-serif; color:#333; max-width:600px; margin:48px auto; padding:0 16px;">
+<h1 style="color:#2a7d4f;">{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{- with .FormAction}}
+<form method="post" action="{{.}}"><button type="submit">{{$.FormButton}}</button></form>
+{{- end}}
+<p><a href="{{.WebsiteURL}}">Back to Positive News</a></p>
+</body>
+</html>
+`))
+
+// htmlPageData fills htmlPage; FormAction, if set, adds a form posting to it with FormButton.
+type htmlPageData struct {
+	Title      string
+	Message    string
+	FormAction string
+	FormButton string
+	WebsiteURL string
+}
+
+// buildHTMLResponse creates a LambdaFunctionURLResponse with a small HTML page.
+func buildHTMLResponse(status int, title, message, websiteURL string) events.LambdaFunctionURLResponse {
+	return renderHTMLPage(status, htmlPageData{Title: title, Message: message, WebsiteURL: websiteURL})
+}
+
+// renderHTMLPage creates a LambdaFunctionURLResponse with htmlPage for page.
+func renderHTMLPage(status int, page htmlPageData) events.LambdaFunctionURLResponse {
+	var body strings.Builder
+	if err := htmlPage.Execute(&body, page); err != nil {
+		fmt.Println("Error rendering page:", err)
+		return events.LambdaFunctionURLResponse{
+			StatusCode: 500,
+			Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			Body:       "Internal server error",
+		}
+	}
+	return events.LambdaFunctionURLResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "text/html; charset=utf-8"},
+		Body:       body.String(),
+	}
+}
+
+// errorStatus is 400 for errors caused by the request and 500 otherwise.
+func errorStatus(err error) int {
+	if errors.Is(err, helpers.ErrInvalidSubscription) {
+		return 400
+	}
+	return 500
+}
+
+// handleActionRoute handles the original POST / with {"action": "subscribe"|"unsubscribe", "email": ...}.
+func handleActionRoute(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	var sub SubscriptionRequest
+	if err := decodeBody(req, &sub); err != nil {
+		return buildResponse(400, err.Error())
+	}
+	switch sub.Action {
+	case "subscribe":
+		return handleSubscribeRoute(ctx, cfg, req)
+	case "unsubscribe":
+		return handleUnsubscribeRoute(ctx, cfg, req)
+	case "":
+		return buildResponse(400, "Missing required field 'action'.")
+	default:
+		return buildResponse(400, fmt.Sprintf("Unknown action: %s", sub.Action))
+	}
+}
+
+// handleLatestArticlesRoute handles GET /articles/latest, returning the most recent digest's
+// articles as a JSON array. The website calls it on every page view, so successful responses are
+// cached for latestArticlesMaxAge.
+func handleLatestArticlesRoute(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	latestArticles.Lock()
+	defer latestArticles.Unlock()
+	now := time.Now()
+	if now.Before(latestArticles.expires) {
+		return cloneResponse(latestArticles.resp)
+	}
+
+	store, err := helpers.NewArticleStore(ctx, cfg)
+	if err != nil {
+		return buildResponse(500, fmt.Sprintf("Error opening article store: %v", err))
+	}
+	day, articles, err := helpers.LatestDigest(ctx, store, now, latestDigestDays)
+	if err != nil {
+		return buildResponse(500, fmt.Sprintf("Error reading latest digest: %v", err))
+	}
+	if articles == nil {
+		articles = []helpers.ArticleWithContent{}
+	}
+	resp := buildJSONResponse(200, articles)
+	if !day.IsZero() {
+		resp.Headers["X-Digest-Date"] = day.Format("2006-01-02")
+	}
+	resp.Headers["Cache-Control"] = fmt.Sprintf("public, max-age=%d", int(latestArticlesMaxAge.Seconds()))
+	latestArticles.resp = resp
+	latestArticles.expires = now.Add(latestArticlesMaxAge)
+	return cloneResponse(resp)
+}
+
+// cloneResponse copies resp with its own header map, so callers may change the headers.
+func cloneResponse(resp events.LambdaFunctionURLResponse) events.LambdaFunctionURLResponse {
+	headers := make(map[string]string, len(resp.Headers))
+	for k, v := range resp.Headers {
+		headers[k] = v
+	}
+	resp.Headers = headers
+	return resp
+}
+
+// handleHealthRoute handles GET /health.
+func handleHealthRoute(ctx context.Context, cfg helpers.Config, req events.LambdaFunctionURLRequest) events.LambdaFunctionURLResponse {
+	return buildJSONResponse(200, map[string]string{"status": "ok"})
+}
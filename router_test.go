@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"positive-news/helpers"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestLatestArticlesRouteIsCached(t *testing.T) {
+	latestArticles.expires = time.Time{}
+	defer func() { latestArticles.expires = time.Time{} }()
+
+	cfg := helpers.DefaultConfig()
+	cfg.StoreBackend = helpers.StoreBackendFile
+	cfg.StorePath = filepath.Join(t.TempDir(), "store.json")
+	req := events.LambdaFunctionURLRequest{RawPath: "/articles/latest"}
+	req.RequestContext.HTTP.Method = "GET"
+
+	resp := routeRequest(context.Background(), cfg, req)
+	if resp.StatusCode != 200 || resp.Body != "[]" {
+		t.Fatalf("first response = %d %s", resp.StatusCode, resp.Body)
+	}
+	if got, want := resp.Headers["Cache-Control"], "public, max-age=300"; got != want {
+		t.Errorf("Cache-Control = %q, want %q", got, want)
+	}
+
+	// A second request inside the window does not open the store at all.
+	cfg.StoreBackend = "broken"
+	resp.Headers["Cache-Control"] = "changed by the caller"
+	resp = routeRequest(context.Background(), cfg, req)
+	if resp.StatusCode != 200 || resp.Headers["Cache-Control"] != "public, max-age=300" {
+		t.Errorf("cached response = %d %v", resp.StatusCode, resp.Headers)
+	}
+}
+
+func TestUnsubscribeLinkRoutes(t *testing.T) {
+	ctx := context.Background()
+	cfg := helpers.DefaultConfig()
+	cfg.StoreBackend = helpers.StoreBackendFile
+	cfg.StorePath = filepath.Join(t.TempDir(), "store.json")
+	cfg.SNSSubscriptions = false
+	cfg.ConfirmSecret = "0123456789abcdef"
+	store, err := helpers.OpenFileStore(cfg.StorePath, cfg.HistoryDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := helpers.Subscriber{Email: "someone@example.com", Status: helpers.SubscriberActive}
+	if err := store.PutSubscriber(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	token := helpers.SignToken(cfg.ConfirmSecret, helpers.TokenUnsubscribe, sub.Email, time.Now().Add(time.Hour))
+	request := func(method, token string) events.LambdaFunctionURLRequest {
+		req := events.LambdaFunctionURLRequest{RawPath: "/unsubscribe", QueryStringParameters: map[string]string{"token": token}}
+		req.RequestContext.HTTP.Method = method
+		return req
+	}
+
+	// Opening the link only asks for confirmation.
+	resp := routeRequest(ctx, cfg, request("GET", token))
+	if resp.StatusCode != 200 || !strings.Contains(resp.Body, `<form method="post"`) {
+		t.Fatalf("GET /unsubscribe = %d %s", resp.StatusCode, resp.Body)
+	}
+	if got, _ := store.GetSubscriber(ctx, sub.Email); got.Status != helpers.SubscriberActive {
+		t.Fatalf("GET unsubscribed the address: status %q", got.Status)
+	}
+
+	if resp := routeRequest(ctx, cfg, request("POST", token+"x")); resp.StatusCode != 400 {
+		t.Errorf("POST with a tampered token = %d, want 400", resp.StatusCode)
+	}
+	if resp := routeRequest(ctx, cfg, request("POST", token)); resp.StatusCode != 200 {
+		t.Fatalf("POST /unsubscribe = %d %s", resp.StatusCode, resp.Body)
+	}
+	store, err = helpers.OpenFileStore(cfg.StorePath, cfg.HistoryDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.GetSubscriber(ctx, sub.Email); got.Status != helpers.SubscriberUnsubscribed {
+		t.Errorf("status after POST = %q, want %q", got.Status, helpers.SubscriberUnsubscribed)
+	}
+}
+
+func TestConfirmRouteHidesInternalErrors(t *testing.T) {
+	cfg := helpers.DefaultConfig()
+	cfg.StoreBackend = "broken"
+	req := events.LambdaFunctionURLRequest{RawPath: "/confirm", QueryStringParameters: map[string]string{"token": "anything"}}
+	req.RequestContext.HTTP.Method = "GET"
+
+	resp := routeRequest(context.Background(), cfg, req)
+	if resp.StatusCode != 500 {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+	if strings.Contains(resp.Body, "broken") || strings.Contains(resp.Body, "store") {
+		t.Errorf("body leaks the internal error: %s", resp.Body)
+	}
+}